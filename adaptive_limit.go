@@ -0,0 +1,173 @@
+package sling
+
+import (
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// AdaptiveLimit configures the adjustment of a pool's concurrency limit
+// based on the latency and failures of its requests.
+//
+// The limit starts at PoolSize and is kept between Min and Max, idle
+// connections are kept for up to Max concurrent requests.
+type AdaptiveLimit struct {
+	// Min is the lowest concurrency limit, defaults to 1 if less than
+	// or equal to 0.
+	Min int
+
+	// Max is the highest concurrency limit, defaults to PoolSize if less
+	// than or equal to 0.
+	Max int
+
+	// Algorithm creates the LimitAlgorithm for each limited set of slots,
+	// defaults to AIMD(0).
+	Algorithm func() LimitAlgorithm
+}
+
+// LimitSample describes a completed request.
+type LimitSample struct {
+	// Latency is the time the request took, excluding any time spent
+	// waiting for a slot.
+	Latency time.Duration
+
+	// InFlight is the number of requests in flight, including this one,
+	// when the request was started.
+	InFlight int
+
+	// Failed is true for requests which failed with a transport error or
+	// a response status indicating overload, 429 or 5XX.
+	Failed bool
+}
+
+// LimitAlgorithm implementations compute a new concurrency limit from the
+// current limit and a sample of a completed request.
+//
+// Calls to Update are serialized, and the result is clamped to the
+// configured minimum and maximum.
+type LimitAlgorithm interface {
+	Update(limit int, sample LimitSample) int
+}
+
+// AIMD returns a constructor for an additive increase, multiplicative
+// decrease LimitAlgorithm.
+//
+// The limit is increased by one once a limit's worth of requests have
+// succeeded while at least half the limit was in use, and decreased by
+// 10% for every failure or request with a latency above threshold. The
+// latency is ignored if threshold is less than or equal to 0.
+func AIMD(threshold time.Duration) func() LimitAlgorithm {
+	return func() LimitAlgorithm {
+		return &aimd{threshold: threshold}
+	}
+}
+
+type aimd struct {
+	threshold time.Duration
+	increase  float64
+}
+
+func (algorithm *aimd) Update(limit int, sample LimitSample) int {
+	if sample.Failed || (algorithm.threshold > 0 && sample.Latency > algorithm.threshold) {
+		algorithm.increase = 0
+		return int(float64(limit) * 0.9)
+	}
+
+	if sample.InFlight*2 < limit {
+		return limit
+	}
+
+	algorithm.increase += 1 / float64(limit)
+	if algorithm.increase < 1 {
+		return limit
+	}
+	algorithm.increase = 0
+	return limit + 1
+}
+
+// Gradient returns a constructor for a delay based LimitAlgorithm, in the
+// style of TCP Vegas.
+//
+// It compares a smoothed latency to the lowest latency observed, which is
+// assumed to be that of an unloaded backend. The limit shrinks in
+// proportion as the latency grows, by at most half, while leaving room
+// for a small queue so that a lower latency can be discovered. Failures
+// are treated as the largest possible growth in latency.
+func Gradient() func() LimitAlgorithm {
+	return func() LimitAlgorithm {
+		return &gradient{}
+	}
+}
+
+// gradientProbeInterval is the number of samples after which the lowest
+// latency is forgotten, allowing the backend's baseline to rise.
+const gradientProbeInterval = 1000
+
+type gradient struct {
+	estimate        float64
+	latency, lowest float64
+	samples         int
+}
+
+func (algorithm *gradient) Update(limit int, sample LimitSample) int {
+	// The limit differs from the estimate once it has been clamped,
+	// which must not keep growing beyond it.
+	if int(algorithm.estimate) != limit {
+		algorithm.estimate = float64(limit)
+	}
+
+	latency := float64(sample.Latency)
+	if algorithm.latency == 0 {
+		algorithm.latency = latency
+	} else {
+		algorithm.latency = 0.9*algorithm.latency + 0.1*latency
+	}
+
+	algorithm.samples++
+	if algorithm.lowest == 0 || latency < algorithm.lowest || algorithm.samples%gradientProbeInterval == 0 {
+		algorithm.lowest = latency
+	}
+
+	ratio := 0.5
+	if !sample.Failed && algorithm.latency > 0 {
+		ratio = math.Max(0.5, math.Min(1, algorithm.lowest/algorithm.latency))
+	}
+
+	target := algorithm.estimate*ratio + math.Sqrt(algorithm.estimate)
+	if sample.Failed {
+		target = algorithm.estimate * ratio
+	}
+	algorithm.estimate = 0.8*algorithm.estimate + 0.2*target
+	return int(algorithm.estimate)
+}
+
+// adaptiveLimiter applies a LimitAlgorithm to the limit of a semaphore.
+type adaptiveLimiter struct {
+	sync.Mutex
+	algorithm LimitAlgorithm
+	min, max  int
+	semaphore *semaphore
+}
+
+func (limiter *adaptiveLimiter) observe(sample LimitSample) {
+	limiter.Lock()
+	defer limiter.Unlock()
+
+	limit := limiter.algorithm.Update(limiter.semaphore.Limit(), sample)
+	limiter.semaphore.SetLimit(clamp(limit, limiter.min, limiter.max))
+}
+
+func clamp(value, lower, upper int) int {
+	if value < lower {
+		return lower
+	}
+	if value > upper {
+		return upper
+	}
+	return value
+}
+
+func isOverloaded(req *http.Request, response *http.Response, err error) bool {
+	return isFailure(req, response, err) || (err == nil && response.StatusCode == http.StatusTooManyRequests)
+}
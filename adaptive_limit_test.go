@@ -0,0 +1,97 @@
+package sling
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAIMD_DecreasesLimitOnFailures(t *testing.T) {
+	algorithm := AIMD(0)()
+
+	if limit := algorithm.Update(10, LimitSample{InFlight: 10, Failed: true}); limit != 9 {
+		t.Errorf("Expected limit to be decreased to %d, but was %d", 9, limit)
+	}
+}
+
+func TestAIMD_DecreasesLimitOnSlowRequests(t *testing.T) {
+	algorithm := AIMD(time.Second)()
+
+	if limit := algorithm.Update(10, LimitSample{InFlight: 10, Latency: 2 * time.Second}); limit != 9 {
+		t.Errorf("Expected limit to be decreased to %d, but was %d", 9, limit)
+	}
+}
+
+func TestAIMD_IncreasesLimitOnceALimitOfRequestsSucceeded(t *testing.T) {
+	algorithm := AIMD(0)()
+
+	limit := 4
+	for i := 0; i < 4; i++ {
+		limit = algorithm.Update(limit, LimitSample{InFlight: 4})
+	}
+
+	if limit != 5 {
+		t.Errorf("Expected limit to be increased to %d, but was %d", 5, limit)
+	}
+}
+
+func TestAIMD_KeepsLimitWhenUnderutilized(t *testing.T) {
+	algorithm := AIMD(0)()
+
+	limit := 4
+	for i := 0; i < 8; i++ {
+		limit = algorithm.Update(limit, LimitSample{InFlight: 1})
+	}
+
+	if limit != 4 {
+		t.Errorf("Expected limit to remain %d, but was %d", 4, limit)
+	}
+}
+
+func TestGradient_DecreasesLimitAsLatencyGrows(t *testing.T) {
+	algorithm := Gradient()()
+
+	limit := 20
+	for i := 0; i < 10; i++ {
+		limit = algorithm.Update(limit, LimitSample{InFlight: limit, Latency: 10 * time.Millisecond})
+	}
+	steady := limit
+
+	for i := 0; i < 50; i++ {
+		limit = algorithm.Update(limit, LimitSample{InFlight: limit, Latency: 100 * time.Millisecond})
+	}
+
+	if limit >= steady {
+		t.Errorf("Expected limit to decrease from %d as latency grew, but was %d", steady, limit)
+	}
+}
+
+func TestAdaptiveLimit_PoolLimitIsReducedForFailingBackends(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	pool := NewConnectionPool(Config{PoolSize: 4, AdaptiveLimit: &AdaptiveLimit{Min: 2}})
+	client, _ := pool.HTTP(server.URL)
+
+	if limit := pool.Stats().Limit; limit != 4 {
+		t.Errorf("Expected initial limit to be %d, but was %d", 4, limit)
+	}
+
+	for i := 0; i < 10; i++ {
+		client.Do(JSONRequest("GET", ""))
+	}
+
+	if limit := pool.Stats().Limit; limit != 2 {
+		t.Errorf("Expected limit to have been reduced to its minimum %d, but was %d", 2, limit)
+	}
+}
+
+func TestAdaptiveLimit_RequestsShedByThePoolAreNotOverloads(t *testing.T) {
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	if isOverloaded(req, nil, ErrPoolSaturated) {
+		t.Error("Expected requests shed by the pool not to count as overloads")
+	}
+}
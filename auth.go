@@ -0,0 +1,328 @@
+package sling
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Authenticator implementations add credentials to requests.
+type Authenticator interface {
+	// Authenticate adds credentials to req before it is sent.
+	Authenticate(req *http.Request) error
+
+	// Challenge is called with responses to req with the status 401
+	// Unauthorized, and returns whether req should be authenticated and
+	// sent again. Requests are retried at most once, and only if their
+	// body can be sent again.
+	Challenge(req *http.Request, response *http.Response) bool
+}
+
+// WithAuthenticator sets the Authenticator of a HTTP client or Cluster,
+// overriding the pool's Authenticator.
+func WithAuthenticator(authenticator Authenticator) HTTPOption {
+	return func(options *httpOptions) {
+		options.authenticator = authenticator
+	}
+}
+
+// BasicAuth returns an Authenticator using HTTP Basic authentication.
+func BasicAuth(username, password string) Authenticator {
+	return BasicAuthFrom(StaticCredentials(Credentials{Username: username, Password: password}))
+}
+
+// BasicAuthFrom returns an Authenticator using HTTP Basic authentication
+// with the Username and Password of the provider's credentials.
+func BasicAuthFrom(provider CredentialProvider) Authenticator {
+	return &basicAuth{provider}
+}
+
+type basicAuth struct {
+	provider CredentialProvider
+}
+
+func (auth *basicAuth) Authenticate(req *http.Request) error {
+	credentials, err := auth.provider.Credentials()
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(credentials.Username, credentials.Password)
+	return nil
+}
+
+func (auth *basicAuth) Challenge(*http.Request, *http.Response) bool {
+	return false
+}
+
+// BearerToken returns an Authenticator sending a static bearer token.
+func BearerToken(token string) Authenticator {
+	return BearerTokenFrom(StaticCredentials(Credentials{Token: token}))
+}
+
+// BearerTokenFrom returns an Authenticator sending the Token of the
+// provider's credentials as a bearer token.
+func BearerTokenFrom(provider CredentialProvider) Authenticator {
+	return &bearerToken{provider}
+}
+
+type bearerToken struct {
+	provider CredentialProvider
+}
+
+func (token *bearerToken) Authenticate(req *http.Request) error {
+	credentials, err := token.provider.Credentials()
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+credentials.Token)
+	return nil
+}
+
+func (token *bearerToken) Challenge(*http.Request, *http.Response) bool {
+	return false
+}
+
+// DigestAuth returns an Authenticator using HTTP Digest authentication
+// with the MD5 or SHA-256 algorithms and a quality of protection of auth.
+//
+// Requests are sent without credentials until the server has issued a
+// challenge, which is then used for all further requests to its host.
+func DigestAuth(username, password string) Authenticator {
+	return DigestAuthFrom(StaticCredentials(Credentials{Username: username, Password: password}))
+}
+
+// DigestAuthFrom returns an Authenticator like DigestAuth using the
+// Username and Password of the provider's credentials.
+func DigestAuthFrom(provider CredentialProvider) Authenticator {
+	return &digestAuth{provider: provider, challenges: make(map[string]*digestChallenge)}
+}
+
+type digestAuth struct {
+	provider CredentialProvider
+
+	// Mutex guards challenges, the last challenge of each host.
+	sync.Mutex
+	challenges map[string]*digestChallenge
+}
+
+// digestChallenge is a challenge along with the number of requests which
+// have been authenticated using it.
+type digestChallenge struct {
+	params map[string]string
+	count  int
+}
+
+func (auth *digestAuth) Authenticate(req *http.Request) error {
+	auth.Lock()
+	defer auth.Unlock()
+	last := auth.challenges[req.URL.Host]
+	if last == nil {
+		return nil
+	}
+	credentials, err := auth.provider.Credentials()
+	if err != nil {
+		return err
+	}
+
+	last.count++
+	challenge := last.params
+	algorithm := challenge["algorithm"]
+	newHash := digestHash(algorithm)
+	cnonce, err := newCNonce()
+	if err != nil {
+		return err
+	}
+
+	uri, nc := req.URL.RequestURI(), fmt.Sprintf("%08x", last.count)
+	ha1 := hashHex(newHash, credentials.Username, challenge["realm"], credentials.Password)
+	if strings.HasSuffix(strings.ToUpper(algorithm), "-SESS") {
+		ha1 = hashHex(newHash, ha1, challenge["nonce"], cnonce)
+	}
+	ha2 := hashHex(newHash, req.Method, uri)
+
+	var response string
+	if challenge["qop"] == "" {
+		response = hashHex(newHash, ha1, challenge["nonce"], ha2)
+	} else {
+		response = hashHex(newHash, ha1, challenge["nonce"], nc, cnonce, "auth", ha2)
+	}
+
+	header := fmt.Sprintf(`Digest username=%s, realm=%s, nonce=%s, uri=%s, response="%s"`,
+		quoteParam(credentials.Username), quoteParam(challenge["realm"]), quoteParam(challenge["nonce"]), quoteParam(uri), response)
+	if algorithm != "" {
+		header += ", algorithm=" + algorithm
+	}
+	if opaque, ok := challenge["opaque"]; ok {
+		header += ", opaque=" + quoteParam(opaque)
+	}
+	if challenge["qop"] != "" {
+		header += fmt.Sprintf(`, qop=auth, nc=%s, cnonce="%s"`, nc, cnonce)
+	}
+	req.Header.Set("Authorization", header)
+	return nil
+}
+
+func (auth *digestAuth) Challenge(req *http.Request, response *http.Response) bool {
+	for _, value := range response.Header.Values("WWW-Authenticate") {
+		scheme, params := parseChallenge(value)
+		if !strings.EqualFold(scheme, "Digest") || !isSupportedDigest(params) {
+			continue
+		}
+
+		auth.Lock()
+		defer auth.Unlock()
+		// A repeated challenge for the nonce which was just used
+		// means the credentials were rejected, unless the server says
+		// that the nonce is merely stale.
+		last := auth.challenges[req.URL.Host]
+		if last != nil && last.params["nonce"] == params["nonce"] && !strings.EqualFold(params["stale"], "true") {
+			return false
+		}
+		if qop := params["qop"]; qop != "" {
+			params["qop"] = "auth"
+		}
+		auth.challenges[req.URL.Host] = &digestChallenge{params: params}
+		return true
+	}
+	return false
+}
+
+// isSupportedDigest returns whether the algorithm and quality of protection
+// of a Digest challenge are supported.
+func isSupportedDigest(params map[string]string) bool {
+	if digestHash(params["algorithm"]) == nil || params["nonce"] == "" {
+		return false
+	}
+	qop := params["qop"]
+	if qop == "" {
+		return true
+	}
+	for _, option := range strings.Split(qop, ",") {
+		if strings.TrimSpace(option) == "auth" {
+			return true
+		}
+	}
+	return false
+}
+
+func digestHash(algorithm string) func() hash.Hash {
+	switch strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS") {
+	case "", "MD5":
+		return md5.New
+	case "SHA-256":
+		return sha256.New
+	default:
+		return nil
+	}
+}
+
+func hashHex(newHash func() hash.Hash, values ...string) string {
+	hash := newHash()
+	hash.Write([]byte(strings.Join(values, ":")))
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func newCNonce() (string, error) {
+	cnonce := make([]byte, 16)
+	if _, err := rand.Read(cnonce); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(cnonce), nil
+}
+
+// quoteParam returns value as a quoted string of an authentication header.
+func quoteParam(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+// parseChallenge splits a WWW-Authenticate header into its scheme and
+// parameters, whose values may be quoted.
+func parseChallenge(header string) (string, map[string]string) {
+	header = strings.TrimSpace(header)
+	scheme, rest := header, ""
+	if i := strings.IndexByte(header, ' '); i >= 0 {
+		scheme, rest = header[:i], header[i+1:]
+	}
+
+	params := make(map[string]string)
+	for rest = strings.TrimSpace(rest); rest != ""; rest = strings.TrimLeft(rest, ", ") {
+		i := strings.IndexByte(rest, '=')
+		if i < 0 {
+			break
+		}
+		name := strings.ToLower(strings.TrimSpace(rest[:i]))
+		rest = strings.TrimSpace(rest[i+1:])
+
+		var value strings.Builder
+		if strings.HasPrefix(rest, `"`) {
+			end := 1
+			for ; end < len(rest) && rest[end] != '"'; end++ {
+				if rest[end] == '\\' && end+1 < len(rest) {
+					end++
+				}
+				value.WriteByte(rest[end])
+			}
+			rest = rest[min(end+1, len(rest)):]
+		} else if end := strings.IndexByte(rest, ','); end >= 0 {
+			value.WriteString(strings.TrimSpace(rest[:end]))
+			rest = rest[end:]
+		} else {
+			value.WriteString(rest)
+			rest = ""
+		}
+		params[name] = value.String()
+	}
+	return scheme, params
+}
+
+// authenticatingHTTPClient adds credentials to requests, sending them again
+// once if the server issues a challenge which its Authenticator accepts.
+type authenticatingHTTPClient struct {
+	netHTTPClient
+	authenticator Authenticator
+	maxDrainSize  int64
+}
+
+func (client *authenticatingHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if err := client.authenticator.Authenticate(req); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+
+	response, err := client.netHTTPClient.Do(req)
+	if err != nil || response.StatusCode != http.StatusUnauthorized || !isReplayable(req) {
+		return response, err
+	}
+	if !client.authenticator.Challenge(req, response) {
+		return response, nil
+	}
+	closeResponse(response, client.maxDrainSize)
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	if err := client.authenticator.Authenticate(retry); err != nil {
+		if retry.Body != nil {
+			retry.Body.Close()
+		}
+		return nil, err
+	}
+	return client.netHTTPClient.Do(retry)
+}
+
+// isReplayable returns whether the body of req can be sent again.
+func isReplayable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
@@ -0,0 +1,172 @@
+package sling
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// newAuthorizationServer starts a server which records the Authorization
+// header of the last request.
+func newAuthorizationServer() (*httptest.Server, *atomic.Value) {
+	authorization := new(atomic.Value)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization.Store(r.Header.Get("Authorization"))
+		w.Write([]byte("{}"))
+	}))
+	return server, authorization
+}
+
+func TestAuth_CredentialsAreTakenFromTheBaseURL(t *testing.T) {
+	server, authorization := newAuthorizationServer()
+	defer server.Close()
+
+	client, _ := NewConnectionPool(Config{}).HTTP(strings.Replace(server.URL, "http://", "http://user:secret@", 1))
+	if err := client.Do(JSONRequest("GET", "")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	req := &http.Request{Header: http.Header{}}
+	req.SetBasicAuth("user", "secret")
+	if actual, expected := authorization.Load(), req.Header.Get("Authorization"); actual != expected {
+		t.Errorf("Expected Authorization to be %q, but was %q", expected, actual)
+	}
+	if user := client.(*httpClient).URL.User; user != nil {
+		t.Errorf("Expected credentials to be removed from the base URL, but found %v", user)
+	}
+}
+
+func TestAuth_AuthenticatorsOfClientsOverrideThePool(t *testing.T) {
+	server, authorization := newAuthorizationServer()
+	defer server.Close()
+
+	pool := NewConnectionPool(Config{Authenticator: BearerToken("pool")})
+	poolClient, _ := pool.HTTP(server.URL)
+	client, _ := pool.HTTP(server.URL, WithAuthenticator(BearerToken("client")))
+
+	poolClient.Do(JSONRequest("GET", ""))
+	if actual := authorization.Load(); actual != "Bearer pool" {
+		t.Errorf("Expected Authorization to be %q, but was %q", "Bearer pool", actual)
+	}
+
+	client.Do(JSONRequest("GET", ""))
+	if actual := authorization.Load(); actual != "Bearer client" {
+		t.Errorf("Expected Authorization to be %q, but was %q", "Bearer client", actual)
+	}
+}
+
+func TestAuth_DigestChallengesAreAnswered(t *testing.T) {
+	var requests, bodies int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if body, _ := ioutil.ReadAll(r.Body); len(body) > 0 {
+			atomic.AddInt32(&bodies, 1)
+		}
+
+		_, params := parseChallenge(r.Header.Get("Authorization"))
+		ha1 := hashHex(digestHash("SHA-256"), "user", "test", "secret")
+		ha2 := hashHex(digestHash("SHA-256"), r.Method, r.URL.RequestURI())
+		expected := hashHex(digestHash("SHA-256"), ha1, "abc", params["nc"], params["cnonce"], "auth", ha2)
+		if params["response"] != expected || params["opaque"] != "x\"y" {
+			w.Header().Set("WWW-Authenticate", `Digest realm="test", nonce="abc", qop="auth,auth-int", algorithm=SHA-256, opaque="x\"y"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	client, _ := NewConnectionPool(Config{}).HTTP(server.URL, WithAuthenticator(DigestAuth("user", "secret")))
+	for i := 0; i < 2; i++ {
+		if err := client.Do(JSONRequest("POST", "items").Body(map[string]int{"i": i})); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	if count, withBody := atomic.LoadInt32(&requests), atomic.LoadInt32(&bodies); count != 3 || withBody != 3 {
+		t.Errorf("Expected %d requests with a body after one challenge, but got %d with %d bodies", 3, count, withBody)
+	}
+}
+
+// newDigestServer starts a server challenging requests to authenticate as
+// user with password secret using nonce, which counts rejected requests.
+func newDigestServer(nonce string) (*httptest.Server, *int32) {
+	rejected := new(int32)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, params := parseChallenge(r.Header.Get("Authorization"))
+		ha1 := hashHex(digestHash("MD5"), "user", "test", "secret")
+		ha2 := hashHex(digestHash("MD5"), r.Method, r.URL.RequestURI())
+		if params["uri"] != r.URL.RequestURI() || params["response"] != hashHex(digestHash("MD5"), ha1, nonce, ha2) {
+			atomic.AddInt32(rejected, 1)
+			w.Header().Set("WWW-Authenticate", `Digest realm="test", nonce="`+nonce+`"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("{}"))
+	}))
+	return server, rejected
+}
+
+func TestAuth_DigestRequestsOfClustersAreAuthenticatedForTheirEndpoint(t *testing.T) {
+	first, firstRejected := newDigestServer("first")
+	defer first.Close()
+	second, secondRejected := newDigestServer("second")
+	defer second.Close()
+
+	cluster, _ := NewConnectionPool(Config{}).HTTPCluster([]string{first.URL + "/a", second.URL + "/b"}, WithAuthenticator(DigestAuth("user", "secret")))
+	for i := 0; i < 4; i++ {
+		if err := cluster.Do(JSONRequest("GET", "items")); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	if first, second := atomic.LoadInt32(firstRejected), atomic.LoadInt32(secondRejected); first != 1 || second != 1 {
+		t.Errorf("Expected a single challenge by each endpoint, but got %d and %d", first, second)
+	}
+}
+
+func TestAuth_CredentialsOfClusterEndpointsAreTakenFromTheirBaseURL(t *testing.T) {
+	first, firstAuthorization := newAuthorizationServer()
+	defer first.Close()
+	second, secondAuthorization := newAuthorizationServer()
+	defer second.Close()
+
+	cluster, _ := NewConnectionPool(Config{}).HTTPCluster([]string{
+		strings.Replace(first.URL, "http://", "http://first:secret@", 1),
+		strings.Replace(second.URL, "http://", "http://second:secret@", 1),
+	})
+	for i := 0; i < 2; i++ {
+		if err := cluster.Do(JSONRequest("GET", "")); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	for user, authorization := range map[string]*atomic.Value{"first": firstAuthorization, "second": secondAuthorization} {
+		req := &http.Request{Header: http.Header{}}
+		req.SetBasicAuth(user, "secret")
+		if actual, expected := authorization.Load(), req.Header.Get("Authorization"); actual != expected {
+			t.Errorf("Expected Authorization to be %q, but was %q", expected, actual)
+		}
+	}
+}
+
+func TestAuth_RejectedDigestCredentialsAreNotRetried(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("WWW-Authenticate", `Digest realm="test", nonce="abc"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	client, _ := NewConnectionPool(Config{}).HTTP(server.URL, WithAuthenticator(DigestAuth("user", "wrong")))
+	client.Do(JSONRequest("GET", ""))
+	client.Do(JSONRequest("GET", ""))
+
+	if count := atomic.LoadInt32(&requests); count != 3 {
+		t.Errorf("Expected %d requests, but got %d", 3, count)
+	}
+}
@@ -0,0 +1,92 @@
+package sling
+
+import (
+	"hash/fnv"
+	"sync/atomic"
+)
+
+// Balancer implementations choose the endpoint of a Cluster to which
+// a request will be sent.
+//
+// Pick is called concurrently for every request with the current status of
+// all endpoints available for the request, which is never empty, and the
+// request's key. The key is the one set on the request builder, or the
+// request's path and query otherwise. It must return the index of the
+// chosen endpoint.
+type Balancer interface {
+	Pick(endpoints []EndpointStatus, key string) int
+}
+
+type roundRobin struct {
+	next uint64
+}
+
+// RoundRobin returns a Balancer which chooses endpoints in turn.
+func RoundRobin() Balancer {
+	return &roundRobin{}
+}
+
+func (balancer *roundRobin) Pick(endpoints []EndpointStatus, key string) int {
+	return int((atomic.AddUint64(&balancer.next, 1) - 1) % uint64(len(endpoints)))
+}
+
+type leastInFlight struct {
+	next uint64
+}
+
+// LeastInFlight returns a Balancer which chooses the endpoint with the
+// fewest requests in flight, ties are broken in turn.
+func LeastInFlight() Balancer {
+	return &leastInFlight{}
+}
+
+func (balancer *leastInFlight) Pick(endpoints []EndpointStatus, key string) int {
+	offset := int(atomic.AddUint64(&balancer.next, 1) % uint64(len(endpoints)))
+	chosen := offset
+	for i := range endpoints {
+		index := (offset + i) % len(endpoints)
+		if endpoints[index].InFlight < endpoints[chosen].InFlight {
+			chosen = index
+		}
+	}
+	return chosen
+}
+
+type consistentHash struct{}
+
+// ConsistentHash returns a Balancer which always chooses the same endpoint
+// for a given request key, as long as that endpoint is available.
+//
+// When an endpoint becomes unavailable only its keys move to the remaining
+// endpoints, and an added endpoint only takes over its share of keys.
+func ConsistentHash() Balancer {
+	return consistentHash{}
+}
+
+// This is rendezvous hashing, which unlike a hash ring needs no state to be
+// kept in sync with the set of endpoints.
+func (consistentHash) Pick(endpoints []EndpointStatus, key string) int {
+	var chosen int
+	var highest uint64
+	for i, endpoint := range endpoints {
+		hash := fnv.New64a()
+		hash.Write([]byte(endpoint.URL))
+		hash.Write([]byte{0})
+		hash.Write([]byte(key))
+		if weight := mix64(hash.Sum64()); i == 0 || weight > highest {
+			chosen, highest = i, weight
+		}
+	}
+	return chosen
+}
+
+// mix64 is the finalizer of SplitMix64, which spreads similar FNV hashes
+// of keys sharing a prefix across the whole range.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,87 @@
+package sling
+
+import (
+	"fmt"
+	"testing"
+)
+
+func newTestEndpointStatuses(inFlight ...int) []EndpointStatus {
+	endpoints := make([]EndpointStatus, len(inFlight))
+	for i := range inFlight {
+		endpoints[i] = EndpointStatus{
+			URL:      fmt.Sprintf("http://endpoint%d.example.com/", i),
+			InFlight: inFlight[i],
+		}
+	}
+	return endpoints
+}
+
+func TestRoundRobin_PicksEndpointsInTurn(t *testing.T) {
+	balancer := RoundRobin()
+	endpoints := newTestEndpointStatuses(0, 0, 0)
+
+	for i := 0; i < 6; i++ {
+		if expected, picked := i%3, balancer.Pick(endpoints, ""); expected != picked {
+			t.Errorf("Expected pick %d to be endpoint %d, but was %d", i, expected, picked)
+		}
+	}
+}
+
+func TestLeastInFlight_PicksEndpointWithFewestRequests(t *testing.T) {
+	balancer := LeastInFlight()
+	endpoints := newTestEndpointStatuses(3, 1, 2)
+
+	for i := 0; i < 3; i++ {
+		if picked := balancer.Pick(endpoints, ""); picked != 1 {
+			t.Errorf("Expected endpoint 1 to be picked, but was %d", picked)
+		}
+	}
+}
+
+func TestLeastInFlight_SpreadsTiesAcrossEndpoints(t *testing.T) {
+	balancer := LeastInFlight()
+	endpoints := newTestEndpointStatuses(0, 0)
+
+	picked := make(map[int]bool)
+	for i := 0; i < 2; i++ {
+		picked[balancer.Pick(endpoints, "")] = true
+	}
+
+	if len(picked) != 2 {
+		t.Errorf("Expected both endpoints to be picked, but picked %v", picked)
+	}
+}
+
+func TestConsistentHash_PicksTheSameEndpointForAKey(t *testing.T) {
+	balancer := ConsistentHash()
+	endpoints := newTestEndpointStatuses(0, 0, 0, 0)
+
+	picked := make(map[int]bool)
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("/db/doc%d", i)
+		first := balancer.Pick(endpoints, key)
+		if second := balancer.Pick(endpoints, key); first != second {
+			t.Errorf("Expected key '%s' to pick endpoint %d again, but picked %d", key, first, second)
+		}
+		picked[first] = true
+	}
+
+	if len(picked) != len(endpoints) {
+		t.Errorf("Expected keys to be spread across all endpoints, but picked %v", picked)
+	}
+}
+
+func TestConsistentHash_OnlyMovesKeysOfRemovedEndpoints(t *testing.T) {
+	balancer := ConsistentHash()
+	endpoints := newTestEndpointStatuses(0, 0, 0, 0)
+	remaining := append(append([]EndpointStatus{}, endpoints[:2]...), endpoints[3:]...)
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("/db/doc%d", i)
+		before := endpoints[balancer.Pick(endpoints, key)].URL
+		after := remaining[balancer.Pick(remaining, key)].URL
+		if before != endpoints[2].URL && before != after {
+			t.Errorf("Expected key '%s' to remain on '%s', but moved to '%s'", key, before, after)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package sling
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Cluster implementations are HTTP clients which dispatch each request to
+// one of several endpoints serving the same API.
+//
+// Requests are built using the first endpoint as their base URL, and then
+// sent to the same path relative to the chosen endpoint.
+type Cluster interface {
+	HTTP
+
+	// Endpoints returns the current status of the cluster's endpoints.
+	Endpoints() []EndpointStatus
+
+	// Close stops any background health checks and service resolution
+	// of the cluster, and releases its endpoints from the pool unless
+	// used by other clusters. The cluster must not be used afterwards.
+	Close()
+}
+
+// EndpointStatus describes an endpoint of a Cluster.
+type EndpointStatus struct {
+	// URL is the base URL of the endpoint.
+	URL string
+
+	// InFlight is the number of requests to the endpoint which are
+	// currently being made.
+	InFlight int
+
+	// Requests is the total number of requests sent to the endpoint.
+	Requests int64
+
+	// Healthy is false if the endpoint failed its last health probe or
+	// is currently ejected, such endpoints are not used for requests
+	// unless no endpoint of the cluster is healthy.
+	Healthy bool
+
+	// ConsecutiveFailures is the number of requests which failed since
+	// the last success or ejection.
+	ConsecutiveFailures int
+
+	// EjectedUntil is the time at which the endpoint's current ejection
+	// ends, it is zero if the endpoint is not ejected.
+	EjectedUntil time.Time
+}
+
+// endpoint is a base URL with its own slot accounting, shared by all
+// clusters of a pool.
+type endpoint struct {
+	*url.URL
+	netHTTPClient
+	throttledClient    *throttledHTTPClient
+	inFlight, requests int64
+
+	// refs is the number of clusters using the endpoint, it is guarded
+	// by the pool's endpointsMutex.
+	refs int
+}
+
+func (endpoint *endpoint) status() EndpointStatus {
+	return EndpointStatus{
+		URL:      endpoint.URL.String(),
+		InFlight: int(atomic.LoadInt64(&endpoint.inFlight)),
+		Requests: atomic.LoadInt64(&endpoint.requests),
+	}
+}
+
+func (endpoint *endpoint) Do(req *http.Request) (*http.Response, error) {
+	atomic.AddInt64(&endpoint.requests, 1)
+	atomic.AddInt64(&endpoint.inFlight, 1)
+	defer atomic.AddInt64(&endpoint.inFlight, -1)
+	return endpoint.netHTTPClient.Do(req)
+}
+
+// clusterEndpoint is an endpoint along with its health within a cluster.
+type clusterEndpoint struct {
+	*endpoint
+	health endpointHealth
+
+	// client sends requests to the endpoint, authenticating, compressing
+	// and signing them for it.
+	client netHTTPClient
+}
+
+func (endpoint *clusterEndpoint) status(now time.Time) EndpointStatus {
+	status := endpoint.endpoint.status()
+	endpoint.health.addStatus(&status, now)
+	return status
+}
+
+// balancingHTTPClient sends requests built against baseURL to one of its
+// endpoints as chosen by its balancer.
+type balancingHTTPClient struct {
+	baseURL          *url.URL
+	balancer         Balancer
+	outlierDetection *OutlierDetection
+
+	// endpointClient returns the client sending requests to an endpoint.
+	endpointClient func(*endpoint) netHTTPClient
+
+	// endpointsMutex guards endpoints, which are replaced rather than
+	// modified when services are resolved again.
+	endpointsMutex sync.RWMutex
+	endpoints      []*clusterEndpoint
+}
+
+// newEndpoint returns a cluster endpoint sending requests to endpoint.
+func (client *balancingHTTPClient) newEndpoint(endpoint *endpoint) *clusterEndpoint {
+	return &clusterEndpoint{endpoint: endpoint, client: client.endpointClient(endpoint)}
+}
+
+// current returns the current endpoints of the cluster.
+func (client *balancingHTTPClient) current() []*clusterEndpoint {
+	client.endpointsMutex.RLock()
+	defer client.endpointsMutex.RUnlock()
+	return client.endpoints
+}
+
+func (client *balancingHTTPClient) setEndpoints(endpoints []*clusterEndpoint) {
+	client.endpointsMutex.Lock()
+	defer client.endpointsMutex.Unlock()
+	client.endpoints = endpoints
+}
+
+func (client *balancingHTTPClient) statuses() []EndpointStatus {
+	now := time.Now()
+	endpoints := client.current()
+	statuses := make([]EndpointStatus, len(endpoints))
+	for i, endpoint := range endpoints {
+		statuses[i] = endpoint.status(now)
+	}
+	return statuses
+}
+
+// available returns the endpoints which may currently be used, or all
+// endpoints if none are healthy.
+func (client *balancingHTTPClient) available() []*clusterEndpoint {
+	now := time.Now()
+	endpoints := client.current()
+	available := make([]*clusterEndpoint, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		if endpoint.health.available(now) {
+			available = append(available, endpoint)
+		}
+	}
+	if len(available) == 0 {
+		return endpoints
+	}
+	return available
+}
+
+func (client *balancingHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	key := requestOptionsFrom(req).key
+	if key == "" {
+		key = req.URL.RequestURI()
+	}
+
+	now := time.Now()
+	endpoints := client.available()
+	statuses := make([]EndpointStatus, len(endpoints))
+	for i, endpoint := range endpoints {
+		statuses[i] = endpoint.status(now)
+	}
+
+	index := client.balancer.Pick(statuses, key)
+	if index < 0 || index >= len(endpoints) {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, errors.New("Balancer chose an invalid endpoint")
+	}
+
+	// Requests are authenticated and signed for the chosen endpoint, as
+	// credentials may differ between endpoints and both Digest
+	// authentication and signatures cover the request's URL.
+	endpoint := endpoints[index]
+	req = retarget(req, client.baseURL, endpoint.URL)
+	response, err := endpoint.client.Do(req)
+	if client.outlierDetection != nil {
+		endpoint.health.record(isFailure(req, response, err), client.outlierDetection, time.Now())
+	}
+	return response, err
+}
+
+// retarget returns a copy of req which is sent to to rather than from,
+// keeping the request's path relative to the base URLs.
+func retarget(req *http.Request, from, to *url.URL) *http.Request {
+	if *from == *to {
+		return req
+	}
+
+	target := *req.URL
+	target.Scheme, target.Host, target.User = to.Scheme, to.Host, nil
+	if path, fromPath := target.EscapedPath(), from.EscapedPath(); strings.HasPrefix(path, fromPath) {
+		// Joining the escaped paths keeps escaped slashes, which would
+		// otherwise separate segments of the path sent to the endpoint.
+		escaped := to.EscapedPath() + strings.TrimPrefix(path, fromPath)
+		if unescaped, err := url.PathUnescape(escaped); err == nil {
+			target.Path, target.RawPath = unescaped, escaped
+		}
+	}
+
+	req = req.Clone(req.Context())
+	req.URL = &target
+	req.Host = ""
+	return req
+}
+
+type clusterClient struct {
+	*httpClient
+	pool            *pool
+	balancer        *balancingHTTPClient
+	healthChecker   *healthChecker
+	serviceResolver *serviceResolver
+	closeOnce       sync.Once
+}
+
+func (client *clusterClient) Endpoints() []EndpointStatus {
+	return client.balancer.statuses()
+}
+
+func (client *clusterClient) Close() {
+	client.closeOnce.Do(func() {
+		client.pool.removeCluster(client)
+		client.stop()
+		client.pool.releaseEndpoints(client.balancer.current())
+	})
+}
+
+// stop stops the health checks and service resolution of the cluster.
+func (client *clusterClient) stop() {
+	if client.healthChecker != nil {
+		client.healthChecker.Stop()
+	}
+	if client.serviceResolver != nil {
+		client.serviceResolver.Stop()
+	}
+}
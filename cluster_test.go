@@ -0,0 +1,201 @@
+package sling_test
+
+import (
+	"fmt"
+	"golang.struktur.de/sling"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type recordingServers struct {
+	sync.Mutex
+	servers []*httptest.Server
+	paths   map[string][]string
+}
+
+// newRecordingServers starts count servers which record the paths of
+// requests made to them by server URL.
+func newRecordingServers(count int) *recordingServers {
+	servers := &recordingServers{paths: make(map[string][]string)}
+	for i := 0; i < count; i++ {
+		var server *httptest.Server
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			servers.Lock()
+			servers.paths[server.URL] = append(servers.paths[server.URL], r.URL.Path)
+			servers.Unlock()
+			w.Write([]byte("{}"))
+		}))
+		servers.servers = append(servers.servers, server)
+	}
+	return servers
+}
+
+func (servers *recordingServers) urls(path string) []string {
+	urls := make([]string, len(servers.servers))
+	for i, server := range servers.servers {
+		urls[i] = server.URL + path
+	}
+	return urls
+}
+
+func (servers *recordingServers) Close() {
+	for _, server := range servers.servers {
+		server.Close()
+	}
+}
+
+func TestCluster_RequestsAreBalancedAcrossEndpoints(t *testing.T) {
+	servers := newRecordingServers(3)
+	defer servers.Close()
+
+	cluster, err := sling.NewConnectionPool(sling.Config{}).HTTPCluster(servers.urls(""))
+	if err != nil {
+		t.Fatalf("Unexpected error '%v' creating cluster", err)
+	}
+
+	for i := 0; i < 6; i++ {
+		if err := cluster.Do(sling.JSONRequest("GET", "/doc")); err != nil {
+			t.Fatalf("Unexpected error '%v' making request", err)
+		}
+	}
+
+	for _, server := range servers.servers {
+		if count := len(servers.paths[server.URL]); count != 2 {
+			t.Errorf("Expected 2 requests to have been made to %s, but %d were made", server.URL, count)
+		}
+	}
+
+	for _, endpoint := range cluster.Endpoints() {
+		if endpoint.Requests != 2 || endpoint.InFlight != 0 {
+			t.Errorf("Expected endpoint to have completed 2 requests, but status was %+v", endpoint)
+		}
+	}
+}
+
+func TestCluster_RequestPathsAreRelativeToEachEndpoint(t *testing.T) {
+	servers := newRecordingServers(2)
+	defer servers.Close()
+
+	urls := []string{servers.servers[0].URL + "/first", servers.servers[1].URL + "/second/"}
+	cluster, err := sling.NewConnectionPool(sling.Config{}).HTTPCluster(urls)
+	if err != nil {
+		t.Fatalf("Unexpected error '%v' creating cluster", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := cluster.Do(sling.JSONRequest("GET", "/db/doc")); err != nil {
+			t.Fatalf("Unexpected error '%v' making request", err)
+		}
+	}
+
+	for i, expected := range []string{"/first/db/doc", "/second/db/doc"} {
+		if paths := servers.paths[servers.servers[i].URL]; len(paths) != 1 || paths[0] != expected {
+			t.Errorf("Expected request to path '%s', but requests were %v", expected, paths)
+		}
+	}
+}
+
+func TestCluster_EscapedPathsAreKeptForEachEndpoint(t *testing.T) {
+	var lock sync.Mutex
+	var paths []string
+	recording := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		paths = append(paths, r.URL.EscapedPath())
+		lock.Unlock()
+		w.Write([]byte("{}"))
+	})
+	first, second := httptest.NewServer(recording), httptest.NewServer(recording)
+	defer first.Close()
+	defer second.Close()
+
+	cluster, err := sling.NewConnectionPool(sling.Config{}).HTTPCluster([]string{first.URL + "/db", second.URL + "/db"})
+	if err != nil {
+		t.Fatalf("Unexpected error '%v' creating cluster", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := cluster.Do(sling.JSONRequest("GET", "doc%2Fwith%2Fslash")); err != nil {
+			t.Fatalf("Unexpected error '%v' making request", err)
+		}
+	}
+
+	for _, path := range paths {
+		if path != "/db/doc%2Fwith%2Fslash" {
+			t.Errorf("Expected request to path '/db/doc%%2Fwith%%2Fslash', but requests were %v", paths)
+			break
+		}
+	}
+}
+
+func TestCluster_RequestKeysAreConsistentlyHashed(t *testing.T) {
+	servers := newRecordingServers(3)
+	defer servers.Close()
+
+	cluster, err := sling.NewConnectionPool(sling.Config{}).
+		HTTPCluster(servers.urls(""), sling.WithBalancer(sling.ConsistentHash()))
+	if err != nil {
+		t.Fatalf("Unexpected error '%v' creating cluster", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		if err := cluster.Do(sling.JSONRequest("GET", "/doc").Key("user-42")); err != nil {
+			t.Fatalf("Unexpected error '%v' making request", err)
+		}
+	}
+
+	if len(servers.paths) != 1 {
+		t.Errorf("Expected all requests with the same key to go to one endpoint, but were %v", servers.paths)
+	}
+}
+
+func TestCluster_ErrorsNameTheURLOfTheChosenEndpoint(t *testing.T) {
+	failing := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	first, second := httptest.NewServer(failing), httptest.NewServer(failing)
+	defer first.Close()
+	defer second.Close()
+
+	cluster, err := sling.NewConnectionPool(sling.Config{}).HTTPCluster([]string{first.URL + "/a", second.URL + "/b"})
+	if err != nil {
+		t.Fatalf("Unexpected error '%v' creating cluster", err)
+	}
+
+	for _, expected := range []string{first.URL + "/a/doc", second.URL + "/b/doc"} {
+		err := cluster.Do(sling.JSONRequest("GET", "/doc"))
+		if err == nil || !strings.Contains(err.Error(), expected+" ") {
+			t.Errorf("Expected error to name %s, but got '%v'", expected, err)
+		}
+	}
+}
+
+func TestCluster_MultipartRequestKeysAreConsistentlyHashed(t *testing.T) {
+	servers := newRecordingServers(3)
+	defer servers.Close()
+
+	cluster, err := sling.NewConnectionPool(sling.Config{}).
+		HTTPCluster(servers.urls(""), sling.WithBalancer(sling.ConsistentHash()))
+	if err != nil {
+		t.Fatalf("Unexpected error '%v' creating cluster", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		request := sling.MultipartRequest("POST", fmt.Sprintf("/upload/%d", i)).Field("name", "doc").Key("user-42")
+		if err := cluster.Do(request); err != nil {
+			t.Fatalf("Unexpected error '%v' making request", err)
+		}
+	}
+
+	if len(servers.paths) != 1 {
+		t.Errorf("Expected all requests with the same key to go to one endpoint, but were %v", servers.paths)
+	}
+}
+
+func TestCluster_RequiresABaseURL(t *testing.T) {
+	if _, err := sling.NewConnectionPool(sling.Config{}).HTTPCluster(nil); err == nil {
+		t.Error("No error returned for a cluster without base URLs")
+	}
+}
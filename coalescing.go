@@ -0,0 +1,174 @@
+package sling
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// WithCoalescing enables the coalescing of concurrent GET, HEAD and OPTIONS
+// requests without a body of a HTTP client or Cluster. Requests with the
+// same method, URL and values of the given headers share a single request
+// to the server, and each receives its own copy of the response.
+//
+// The values of the credential headers Authorization, Proxy-Authorization
+// and Cookie are always compared, so requests with different credentials
+// never share a response. Credentials added by the client's Authenticator
+// are the same for all its requests, which are never coalesced with
+// those of other clients.
+//
+// The shared request has no deadline of its own and is cancelled once all
+// requests waiting for it have given up, each of which still fails by its
+// own deadline. Coalesced requests do not record a TraceResult.
+//
+// Response bodies are read into memory before being passed on, up to
+// the pool's MaxResponseSize.
+func WithCoalescing(headers ...string) HTTPOption {
+	canonical := make([]string, len(headers))
+	for i, header := range headers {
+		canonical[i] = http.CanonicalHeaderKey(header)
+	}
+
+	return func(options *httpOptions) {
+		options.coalescing = canonical
+	}
+}
+
+// coalescingHTTPClient shares the responses of concurrent identical
+// requests.
+type coalescingHTTPClient struct {
+	netHTTPClient
+	headers         []string
+	maxResponseSize int64
+
+	sync.Mutex
+	calls map[string]*coalescedCall
+}
+
+func newCoalescingHTTPClient(client netHTTPClient, headers []string, maxResponseSize int64) *coalescingHTTPClient {
+	return &coalescingHTTPClient{
+		netHTTPClient:   client,
+		headers:         headers,
+		maxResponseSize: maxResponseSize,
+		calls:           make(map[string]*coalescedCall),
+	}
+}
+
+// coalescedCall is a request shared by waiters, which is cancelled once
+// all waiters have given up on it.
+type coalescedCall struct {
+	done     chan nothing
+	response *http.Response
+	body     []byte
+	err      error
+	waiters  int
+	cancel   context.CancelFunc
+}
+
+func (client *coalescingHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	if !isRepeatable(req) {
+		return client.netHTTPClient.Do(req)
+	}
+
+	key := client.key(req)
+	client.Lock()
+	call := client.calls[key]
+	if call == nil {
+		ctx, cancel := sharedContext(req)
+		call = &coalescedCall{done: make(chan nothing), cancel: cancel}
+		client.calls[key] = call
+		go client.run(key, call, req.Clone(ctx))
+	}
+	call.waiters++
+	client.Unlock()
+
+	select {
+	case <-call.done:
+	case <-req.Context().Done():
+		client.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			call.cancel()
+			client.forget(key, call)
+		}
+		client.Unlock()
+		return nil, req.Context().Err()
+	}
+
+	if call.err != nil {
+		return nil, call.err
+	}
+	response := *call.response
+	response.Header = call.response.Header.Clone()
+	response.Body = ioutil.NopCloser(bytes.NewReader(call.body))
+	response.Request = req
+	return &response, nil
+}
+
+func (client *coalescingHTTPClient) run(key string, call *coalescedCall, req *http.Request) {
+	defer close(call.done)
+	defer call.cancel()
+
+	response, err := client.netHTTPClient.Do(req)
+	if err == nil {
+		var body io.Reader = response.Body
+		if client.maxResponseSize > 0 {
+			// Reading one byte beyond the limit lets each waiter fail
+			// with ErrResponseTooLarge.
+			body = io.LimitReader(body, client.maxResponseSize+1)
+		}
+		call.body, err = ioutil.ReadAll(body)
+		response.Body.Close()
+	}
+	call.response, call.err = response, err
+
+	client.Lock()
+	client.forget(key, call)
+	client.Unlock()
+}
+
+// sharedContext returns the context of a request shared by the waiters of
+// req. It keeps the settings of its builder, but neither its deadline, as
+// waiters with later deadlines may join, nor its other values or its trace
+// target, which must not be written once the request starting the shared
+// one has returned.
+//
+// The shared request must outlive the context of the request starting it,
+// which may be cancelled while others are still waiting.
+func sharedContext(req *http.Request) (context.Context, context.CancelFunc) {
+	options := requestOptionsFrom(req)
+	options.trace = nil
+	ctx := WithPriority(context.WithValue(context.Background(), requestOptionsKey{}, options), priorityFrom(req))
+	return context.WithCancel(ctx)
+}
+
+// forget stops further requests from joining call, the caller must hold
+// the client's lock.
+func (client *coalescingHTTPClient) forget(key string, call *coalescedCall) {
+	if client.calls[key] == call {
+		delete(client.calls, key)
+	}
+}
+
+// credentialHeaders are the headers identifying the caller of a request,
+// which are part of the key of all coalesced requests.
+var credentialHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+// key identifies requests which may share a response.
+func (client *coalescingHTTPClient) key(req *http.Request) string {
+	var key strings.Builder
+	key.WriteString(req.Method)
+	key.WriteString(" ")
+	key.WriteString(req.URL.String())
+	for _, header := range append(credentialHeaders, client.headers...) {
+		key.WriteString("\n")
+		key.WriteString(header)
+		key.WriteString(": ")
+		key.WriteString(strings.Join(req.Header[header], ", "))
+	}
+	return key.String()
+}
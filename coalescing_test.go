@@ -0,0 +1,213 @@
+package sling_test
+
+import (
+	"context"
+	"golang.struktur.de/sling"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type coalescedResponse struct {
+	Tenant string `json:"tenant"`
+}
+
+// newCoalescingServer starts a server which answers with the request's
+// X-Tenant and Authorization headers once release is closed, counting its
+// requests.
+func newCoalescingServer() (server *httptest.Server, requests *int32, release chan struct{}) {
+	requests, release = new(int32), make(chan struct{})
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		<-release
+		w.Write([]byte(`{"tenant": "` + r.Header.Get("X-Tenant") + r.Header.Get("Authorization") + `"}`))
+	}))
+	return server, requests, release
+}
+
+// doConcurrently runs count requests for tenant, identified by header, whose
+// results are sent to results once complete.
+func doConcurrently(client sling.HTTP, count int, header, tenant string, results chan<- coalescedResponse, requests *sync.WaitGroup) {
+	for i := 0; i < count; i++ {
+		requests.Add(1)
+		go func() {
+			defer requests.Done()
+			var response coalescedResponse
+			if err := client.Do(sling.JSONRequest("GET", "").Header(header, tenant).Success(&response)); err == nil {
+				results <- response
+			}
+		}()
+	}
+}
+
+func TestCoalescing_IdenticalRequestsShareAResponse(t *testing.T) {
+	server, requests, release := newCoalescingServer()
+	defer server.Close()
+
+	pool := sling.NewConnectionPool(sling.Config{})
+	client, _ := pool.HTTP(server.URL, sling.WithCoalescing("X-Tenant"))
+
+	results, waiters := make(chan coalescedResponse, 20), &sync.WaitGroup{}
+	doConcurrently(client, 10, "X-Tenant", "a", results, waiters)
+	doConcurrently(client, 10, "X-Tenant", "b", results, waiters)
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	waiters.Wait()
+	close(results)
+
+	if count := atomic.LoadInt32(requests); count != 2 {
+		t.Errorf("Expected %d requests to have been made, but %d were made", 2, count)
+	}
+
+	tenants := make(map[string]int)
+	for result := range results {
+		tenants[result.Tenant]++
+	}
+	if tenants["a"] != 10 || tenants["b"] != 10 {
+		t.Errorf("Expected each request to decode its own response, but got %v", tenants)
+	}
+}
+
+func TestCoalescing_CancelledRequestsDoNotCancelOthers(t *testing.T) {
+	server, requests, release := newCoalescingServer()
+	defer server.Close()
+
+	pool := sling.NewConnectionPool(sling.Config{})
+	client, _ := pool.HTTP(server.URL, sling.WithCoalescing())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan error, 1)
+	go func() {
+		cancelled <- client.Do(sling.JSONRequest("GET", "").Context(ctx))
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	results, waiters := make(chan coalescedResponse, 1), &sync.WaitGroup{}
+	doConcurrently(client, 1, "X-Tenant", "", results, waiters)
+	time.Sleep(20 * time.Millisecond)
+
+	cancel()
+	if err := <-cancelled; err != context.Canceled {
+		t.Errorf("Expected cancelled request to fail with %v, but got %v", context.Canceled, err)
+	}
+	close(release)
+	waiters.Wait()
+
+	if len(results) != 1 {
+		t.Error("Expected the remaining request to succeed")
+	}
+	if count := atomic.LoadInt32(requests); count != 1 {
+		t.Errorf("Expected %d request to have been made, but %d were made", 1, count)
+	}
+}
+
+func TestCoalescing_RequestsWithDifferentCredentialsDoNotShareAResponse(t *testing.T) {
+	server, requests, release := newCoalescingServer()
+	defer server.Close()
+
+	pool := sling.NewConnectionPool(sling.Config{})
+	client, _ := pool.HTTP(server.URL, sling.WithCoalescing())
+
+	results, waiters := make(chan coalescedResponse, 20), &sync.WaitGroup{}
+	doConcurrently(client, 10, "Authorization", "Bearer a", results, waiters)
+	doConcurrently(client, 10, "Authorization", "Bearer b", results, waiters)
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	waiters.Wait()
+	close(results)
+
+	if count := atomic.LoadInt32(requests); count != 2 {
+		t.Errorf("Expected %d requests to have been made, but %d were made", 2, count)
+	}
+
+	tenants := make(map[string]int)
+	for result := range results {
+		tenants[result.Tenant]++
+	}
+	if tenants["Bearer a"] != 10 || tenants["Bearer b"] != 10 {
+		t.Errorf("Expected each caller to receive its own response, but got %v", tenants)
+	}
+}
+
+func TestCoalescing_RequestsFailByTheirOwnDeadline(t *testing.T) {
+	server, requests, release := newCoalescingServer()
+	defer server.Close()
+
+	pool := sling.NewConnectionPool(sling.Config{})
+	client, _ := pool.HTTP(server.URL, sling.WithCoalescing())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	expired := make(chan error, 1)
+	go func() {
+		expired <- client.Do(sling.JSONRequest("GET", "").Context(ctx))
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	results, waiters := make(chan coalescedResponse, 1), &sync.WaitGroup{}
+	doConcurrently(client, 1, "X-Tenant", "", results, waiters)
+	if err := <-expired; err == nil {
+		t.Error("Expected the request with a deadline to time out")
+	}
+	close(release)
+	waiters.Wait()
+
+	if len(results) != 1 {
+		t.Error("Expected the request without a deadline to succeed")
+	}
+	if count := atomic.LoadInt32(requests); count != 1 {
+		t.Errorf("Expected %d request to have been made, but %d were made", 1, count)
+	}
+}
+
+func TestCoalescing_RequestsWithATotalTimeoutShareAResponse(t *testing.T) {
+	server, requests, release := newCoalescingServer()
+	defer server.Close()
+
+	pool := sling.NewConnectionPool(sling.Config{Timeouts: sling.Timeouts{Total: 10 * time.Second}})
+	client, _ := pool.HTTP(server.URL, sling.WithCoalescing())
+
+	results, waiters := make(chan coalescedResponse, 10), &sync.WaitGroup{}
+	doConcurrently(client, 10, "X-Tenant", "a", results, waiters)
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	waiters.Wait()
+
+	if len(results) != 10 {
+		t.Errorf("Expected all %d requests to succeed, but %d did", 10, len(results))
+	}
+	if count := atomic.LoadInt32(requests); count != 1 {
+		t.Errorf("Expected %d request to have been made, but %d were made", 1, count)
+	}
+}
+
+func TestCoalescing_SharedRequestsDoNotTrace(t *testing.T) {
+	server, _, release := newCoalescingServer()
+	defer server.Close()
+
+	pool := sling.NewConnectionPool(sling.Config{})
+	client, _ := pool.HTTP(server.URL, sling.WithCoalescing())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var trace sling.TraceResult
+	cancelled := make(chan error, 1)
+	go func() {
+		cancelled <- client.Do(sling.JSONRequest("GET", "").Trace(&trace).Context(ctx))
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	results, waiters := make(chan coalescedResponse, 1), &sync.WaitGroup{}
+	doConcurrently(client, 1, "X-Tenant", "", results, waiters)
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	<-cancelled
+
+	close(release)
+	waiters.Wait()
+	if trace != (sling.TraceResult{}) {
+		t.Errorf("Expected no trace to be written after the request returned, but got %+v", trace)
+	}
+}
@@ -0,0 +1,267 @@
+package sling
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// IdentityEncoding may be used to disable request body compression.
+const IdentityEncoding = "identity"
+
+// Codec implementations provide compression for a HTTP content encoding.
+//
+// Codecs for gzip and deflate are registered by default. No codec for zstd
+// is included, as the standard library has no implementation of it and
+// sling has no dependencies beyond it. Applications requiring zstd must
+// register a codec wrapping an implementation such as
+// github.com/klauspost/compress/zstd using RegisterCodec.
+type Codec interface {
+	// ContentEncoding returns the name of the encoding as used by the
+	// Content-Encoding and Accept-Encoding headers.
+	ContentEncoding() string
+
+	// NewWriter returns a writer compressing to w.
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+
+	// NewReader returns a reader decompressing from r.
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+var codecs = struct {
+	sync.RWMutex
+	byEncoding     map[string]Codec
+	acceptEncoding string
+}{byEncoding: make(map[string]Codec)}
+
+// RegisterCodec makes codec available for request compression and
+// response decompression, replacing any codec previously registered
+// for the same content encoding.
+func RegisterCodec(codec Codec) {
+	codecs.Lock()
+	defer codecs.Unlock()
+
+	codecs.byEncoding[strings.ToLower(codec.ContentEncoding())] = codec
+	encodings := make([]string, 0, len(codecs.byEncoding))
+	for encoding := range codecs.byEncoding {
+		encodings = append(encodings, encoding)
+	}
+	sort.Strings(encodings)
+	codecs.acceptEncoding = strings.Join(encodings, ", ")
+}
+
+func lookupCodec(encoding string) (Codec, bool) {
+	codecs.RLock()
+	defer codecs.RUnlock()
+	codec, ok := codecs.byEncoding[strings.ToLower(strings.TrimSpace(encoding))]
+	return codec, ok
+}
+
+func acceptEncoding() string {
+	codecs.RLock()
+	defer codecs.RUnlock()
+	return codecs.acceptEncoding
+}
+
+func init() {
+	RegisterCodec(gzipCodec{})
+	RegisterCodec(deflateCodec{})
+}
+
+type gzipCodec struct{}
+
+func (gzipCodec) ContentEncoding() string {
+	return "gzip"
+}
+
+func (gzipCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriter(w), nil
+}
+
+func (gzipCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// HTTP's deflate is actually the zlib format, see RFC 7230.
+type deflateCodec struct{}
+
+func (deflateCodec) ContentEncoding() string {
+	return "deflate"
+}
+
+func (deflateCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return zlib.NewWriter(w), nil
+}
+
+func (deflateCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return zlib.NewReader(r)
+}
+
+// CompressionStats contains the number of bytes processed by request
+// compression and response decompression.
+type CompressionStats struct {
+	// RequestBytes and RequestBytesCompressed are the sizes of request
+	// bodies before and after compression.
+	RequestBytes, RequestBytesCompressed int64
+
+	// ResponseBytes and ResponseBytesCompressed are the sizes of response
+	// bodies after and before decompression.
+	ResponseBytes, ResponseBytesCompressed int64
+}
+
+type compressionStats CompressionStats
+
+func (stats *compressionStats) snapshot() CompressionStats {
+	return CompressionStats{
+		RequestBytes:            atomic.LoadInt64(&stats.RequestBytes),
+		RequestBytesCompressed:  atomic.LoadInt64(&stats.RequestBytesCompressed),
+		ResponseBytes:           atomic.LoadInt64(&stats.ResponseBytes),
+		ResponseBytesCompressed: atomic.LoadInt64(&stats.ResponseBytesCompressed),
+	}
+}
+
+type countingReader struct {
+	io.Reader
+	count *int64
+}
+
+func (reader *countingReader) Read(p []byte) (int, error) {
+	n, err := reader.Reader.Read(p)
+	atomic.AddInt64(reader.count, int64(n))
+	return n, err
+}
+
+type countingWriter struct {
+	io.Writer
+	count *int64
+}
+
+func (writer *countingWriter) Write(p []byte) (int, error) {
+	n, err := writer.Writer.Write(p)
+	atomic.AddInt64(writer.count, int64(n))
+	return n, err
+}
+
+// compressingHTTPClient compresses request bodies and negotiates and
+// decompresses compressed responses for all encodings with a registered
+// Codec.
+type compressingHTTPClient struct {
+	netHTTPClient
+	encoding     string
+	maxDrainSize int64
+	stats        *compressionStats
+}
+
+func newCompressingHTTPClient(client netHTTPClient, encoding string, maxDrainSize int64, stats *compressionStats) netHTTPClient {
+	return &compressingHTTPClient{
+		netHTTPClient: client,
+		encoding:      encoding,
+		maxDrainSize:  maxDrainSize,
+		stats:         stats,
+	}
+}
+
+func (client *compressingHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	encoding := requestOptionsFrom(req).compression
+	if encoding == "" {
+		encoding = client.encoding
+	}
+	compress := encoding != "" && encoding != IdentityEncoding &&
+		req.Body != nil && req.Body != http.NoBody && req.Header.Get("Content-Encoding") == ""
+	decompress := req.Header.Get("Accept-Encoding") == ""
+
+	if compress || decompress {
+		req = req.Clone(req.Context())
+	}
+
+	if compress {
+		codec, ok := lookupCodec(encoding)
+		if !ok {
+			req.Body.Close()
+			return nil, fmt.Errorf("Unsupported content encoding '%s'", encoding)
+		}
+		req.Body = client.compressBody(codec, req.Body)
+		req.ContentLength = -1
+		req.GetBody = nil
+		req.Header.Set("Content-Encoding", codec.ContentEncoding())
+	}
+
+	if decompress {
+		req.Header.Set("Accept-Encoding", acceptEncoding())
+	}
+
+	response, err := client.netHTTPClient.Do(req)
+	if err != nil || !decompress {
+		return response, err
+	}
+	return client.decompressResponse(response)
+}
+
+func (client *compressingHTTPClient) compressBody(codec Codec, body io.ReadCloser) io.ReadCloser {
+	return pipeBody(func(w io.Writer) error {
+		defer body.Close()
+		compressor, err := codec.NewWriter(&countingWriter{w, &client.stats.RequestBytesCompressed})
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(compressor, &countingReader{body, &client.stats.RequestBytes}); err != nil {
+			compressor.Close()
+			return err
+		}
+		return compressor.Close()
+	})
+}
+
+func (client *compressingHTTPClient) decompressResponse(response *http.Response) (*http.Response, error) {
+	encoding := response.Header.Get("Content-Encoding")
+	codec, ok := lookupCodec(encoding)
+	if response.Body == nil || response.Body == http.NoBody || !ok {
+		return response, nil
+	}
+
+	raw := response.Body
+	decompressor, err := codec.NewReader(&countingReader{raw, &client.stats.ResponseBytesCompressed})
+	if err != nil {
+		// Readers may fail immediately on an empty body, in which case
+		// there is nothing to decompress.
+		if err == io.EOF {
+			return response, nil
+		}
+		closeResponse(response, client.maxDrainSize)
+		return nil, err
+	}
+
+	response.Body = &decompressedBody{
+		Reader:       &countingReader{decompressor, &client.stats.ResponseBytes},
+		decompressor: decompressor,
+		raw:          raw,
+		maxDrainSize: client.maxDrainSize,
+	}
+	response.Header.Del("Content-Encoding")
+	response.Header.Del("Content-Length")
+	response.ContentLength = -1
+	response.Uncompressed = true
+	return response, nil
+}
+
+type decompressedBody struct {
+	io.Reader
+	decompressor io.Closer
+	raw          io.ReadCloser
+	maxDrainSize int64
+}
+
+func (body *decompressedBody) Close() error {
+	body.decompressor.Close()
+	// Decompressors may stop reading at the end of the compressed
+	// stream, so any remainder must be drained to allow the connection
+	// to be reused.
+	drainBody(body.raw, body.maxDrainSize)
+	return body.raw.Close()
+}
@@ -0,0 +1,129 @@
+package sling_test
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"golang.struktur.de/sling"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newCompressionTestPool(t *testing.T, config sling.Config, handler http.HandlerFunc) (sling.ConnectionPool, sling.HTTP, func()) {
+	server := httptest.NewServer(handler)
+	pool := sling.NewConnectionPool(config)
+	client, err := pool.HTTP(server.URL)
+	if err != nil {
+		server.Close()
+		t.Fatalf("Unexpected error '%v' creating client", err)
+	}
+	return pool, client, server.Close
+}
+
+func TestCompression_RequestBodyIsCompressedUsingThePoolEncoding(t *testing.T) {
+	doc := map[string]string{"field": strings.Repeat("value", 100)}
+	pool, client, done := newCompressionTestPool(t, sling.Config{RequestCompression: "gzip"}, func(w http.ResponseWriter, r *http.Request) {
+		if encoding := r.Header.Get("Content-Encoding"); encoding != "gzip" {
+			t.Errorf("Expected request to have content encoding 'gzip', but was '%s'", encoding)
+		}
+
+		body, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Errorf("Failed to decompress request body: %v", err)
+			return
+		}
+
+		requestJSON := make(map[string]string)
+		if err := json.NewDecoder(body).Decode(&requestJSON); err != nil {
+			t.Errorf("Failed to unmarshal request JSON: %v", err)
+		}
+		if requestJSON["field"] != doc["field"] {
+			t.Errorf("Request json %v did not contain document properties", requestJSON)
+		}
+		w.Write([]byte("{}"))
+	})
+	defer done()
+
+	if err := client.Do(sling.JSONRequest("POST", "").Body(doc)); err != nil {
+		t.Fatalf("Unexpected error '%v' making request", err)
+	}
+
+	stats := pool.Stats().Compression
+	if stats.RequestBytes == 0 || stats.RequestBytesCompressed >= stats.RequestBytes {
+		t.Errorf("Expected compressed request bytes to be less than uncompressed bytes, but stats were %+v", stats)
+	}
+}
+
+func TestCompression_RequestMayDisableCompression(t *testing.T) {
+	_, client, done := newCompressionTestPool(t, sling.Config{RequestCompression: "gzip"}, func(w http.ResponseWriter, r *http.Request) {
+		if encoding := r.Header.Get("Content-Encoding"); encoding != "" {
+			t.Errorf("Expected request to have no content encoding, but was '%s'", encoding)
+		}
+		w.Write([]byte("{}"))
+	})
+	defer done()
+
+	request := sling.JSONRequest("POST", "").Body("uncompressed").Compress(sling.IdentityEncoding)
+	if err := client.Do(request); err != nil {
+		t.Fatalf("Unexpected error '%v' making request", err)
+	}
+}
+
+func TestCompression_MultipartRequestMayDisableCompression(t *testing.T) {
+	_, client, done := newCompressionTestPool(t, sling.Config{RequestCompression: "gzip"}, func(w http.ResponseWriter, r *http.Request) {
+		if encoding := r.Header.Get("Content-Encoding"); encoding != "" {
+			t.Errorf("Expected request to have no content encoding, but was '%s'", encoding)
+		}
+		w.Write([]byte("{}"))
+	})
+	defer done()
+
+	request := sling.MultipartRequest("POST", "").Field("a", "uncompressed").Compress(sling.IdentityEncoding)
+	if err := client.Do(request); err != nil {
+		t.Fatalf("Unexpected error '%v' making request", err)
+	}
+}
+
+func TestCompression_RequestFailsForUnregisteredEncodings(t *testing.T) {
+	_, client, done := newCompressionTestPool(t, sling.Config{}, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Unexpected request made with unregistered encoding")
+	})
+	defer done()
+
+	err := client.Do(sling.JSONRequest("POST", "").Body("body").Compress("x-unknown"))
+	if err == nil || !strings.Contains(err.Error(), "x-unknown") {
+		t.Errorf("Expected an unsupported encoding error, but was '%v'", err)
+	}
+}
+
+func TestCompression_ResponseIsDecompressed(t *testing.T) {
+	pool, client, done := newCompressionTestPool(t, sling.Config{}, func(w http.ResponseWriter, r *http.Request) {
+		if accepts := r.Header.Get("Accept-Encoding"); !strings.Contains(accepts, "deflate") {
+			t.Errorf("Expected request to accept deflate encoding, but accepts '%s'", accepts)
+		}
+
+		w.Header().Set("Content-Encoding", "deflate")
+		body := zlib.NewWriter(w)
+		body.Write([]byte(`{"Foo": 56}` + "\n"))
+		body.Close()
+	})
+	defer done()
+
+	responseData := struct {
+		Foo int
+	}{}
+	if err := client.Do(sling.JSONRequest("GET", "").Success(&responseData)); err != nil {
+		t.Fatalf("Unexpected error '%v' making request", err)
+	}
+
+	if responseData.Foo != 56 {
+		t.Errorf("Expected response JSON to have been decoded into %v", responseData)
+	}
+
+	stats := pool.Stats().Compression
+	if stats.ResponseBytes != int64(len(`{"Foo": 56}`+"\n")) || stats.ResponseBytesCompressed == 0 {
+		t.Errorf("Expected response byte counts to have been recorded, but stats were %+v", stats)
+	}
+}
@@ -1,24 +1,226 @@
 package sling
 
 import (
+	"context"
 	"crypto/tls"
+	"errors"
+	"net"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
 )
 
 // DefaultPoolSize is the default maximum number of outbound connections.
 const DefaultPoolSize = 8
 
+// DefaultMaxDrainSize is the default maximum number of bytes read from
+// unprocessed response bodies to allow their connection to be reused.
+const DefaultMaxDrainSize = 64 * 1024
+
+// DefaultIdleConnTimeout is the default time after which idle connections
+// are closed.
+const DefaultIdleConnTimeout = 90 * time.Second
+
+// DefaultTCPKeepAlive is the default interval between TCP keep-alive probes.
+const DefaultTCPKeepAlive = 30 * time.Second
+
+// DefaultStarvationTimeout is the default time after which a request
+// waiting for a slot is granted one regardless of its priority.
+const DefaultStarvationTimeout = 5 * time.Second
+
 // Config contains the options for a Pool, all settings have
 // sane defaults if omitted.
 type Config struct {
 	// PoolSize is the maximum number of connections which will
 	// be opened to the server, defaults to DefaultPoolSize
-	// if less then or equal to 0.
+	// if less then or equal to 0. For HTTP/2 it is the maximum
+	// number of concurrent streams instead.
 	PoolSize int
 
 	// SkipSSLValidation should be set to true if SSL validation is
 	// not desired.
 	SkipSSLValidation bool
+
+	// Pins contains the accepted SPKI pins of hosts, see SPKIPin. Requests
+	// to a host with pins fail with ErrPinMismatch unless its certificate
+	// chain contains one of them. If SkipSSLValidation is set, pins are
+	// still checked but only the server's own certificate may match. Several
+	// pins may be given to allow keys to be rotated.
+	//
+	// Hosts are keyed by name without a port, as pins are matched against
+	// the server name sent during the TLS handshake. Hosts given as IP
+	// addresses are not sent a server name and thus cannot be pinned.
+	Pins map[string][]string
+
+	// Timeouts limits the time spent in each phase of a request unless
+	// overridden by the request, requests are not limited by default.
+	Timeouts Timeouts
+
+	// Proxy configures the outbound proxy, requests are sent directly
+	// if nil. Requests to Unix domain sockets are never proxied. Creating
+	// clients fails with ErrUnsupportedProxy if its URL is not supported.
+	Proxy *Proxy
+
+	// HTTP2 configures the use of HTTP/2, only HTTP/1.1 is used by default.
+	HTTP2 HTTP2
+
+	// Dialer is an optional function used to create connections instead
+	// of the default net.Dialer, except for Unix socket base URLs.
+	Dialer func(ctx context.Context, network, address string) (net.Conn, error)
+
+	// TCPKeepAlive is the interval between TCP keep-alive probes of
+	// connections made by the default net.Dialer, defaults to
+	// DefaultTCPKeepAlive if 0. Probes are disabled if less than 0.
+	TCPKeepAlive time.Duration
+
+	// IdleConnTimeout is the time after which idle connections are closed,
+	// defaults to DefaultIdleConnTimeout if 0. Idle connections are kept
+	// until the server closes them if less than 0.
+	IdleConnTimeout time.Duration
+
+	// MaxIdleConnsPerHost is the maximum number of idle connections kept
+	// to each host, defaults to the largest concurrency limit of any host
+	// if less than or equal to 0.
+	MaxIdleConnsPerHost int
+
+	// MaxIdleConns is the maximum number of idle connections kept to all
+	// hosts, it is unlimited if less than or equal to 0.
+	MaxIdleConns int
+
+	// RequestCompression is the content encoding used to compress request
+	// bodies unless overridden by the request, no compression is done
+	// if empty. A Codec must be registered for the encoding.
+	RequestCompression string
+
+	// MaxDrainSize is the maximum number of bytes which will be read and
+	// discarded from the remainder of a response body so that its
+	// connection may be reused. Connections with more data remaining are
+	// closed instead. Defaults to DefaultMaxDrainSize if less than or
+	// equal to 0.
+	MaxDrainSize int64
+
+	// MaxResponseSize is the maximum size of a response body which will
+	// be processed, larger responses fail with ErrResponseTooLarge.
+	// Response sizes are unlimited if less than or equal to 0.
+	MaxResponseSize int64
+
+	// ReservedSlots is the number of the PoolSize slots which are reserved
+	// for requests of each Priority, which other requests may not use.
+	ReservedSlots map[Priority]int
+
+	// StarvationTimeout is the time after which a request waiting for a
+	// slot is granted the next one available regardless of its priority,
+	// defaults to DefaultStarvationTimeout if 0. Requests may starve if
+	// less than 0.
+	StarvationTimeout time.Duration
+
+	// MaxQueueLength is the maximum number of requests which may wait for
+	// a slot of the pool, or of each cluster endpoint, further requests
+	// fail with ErrPoolSaturated. The number of waiting requests is
+	// unlimited if less than or equal to 0.
+	MaxQueueLength int
+
+	// MaxQueueWait is the maximum time a request may wait for a slot before
+	// failing with ErrPoolSaturated, it is unlimited if less than or equal
+	// to 0.
+	MaxQueueWait time.Duration
+
+	// PerHost enables a separate limit of PoolSize concurrent requests for
+	// each host, rather than a single limit shared by all hosts of the pool.
+	// Cluster endpoints on the same host share its limit.
+	PerHost bool
+
+	// HostPoolSizes overrides PoolSize for the hosts it contains if PerHost
+	// is enabled, keyed by host and port as in url.URL's Host.
+	HostPoolSizes map[string]int
+
+	// MaxConcurrentRequests limits the number of requests to all hosts if
+	// PerHost is enabled, it is unlimited if less than or equal to 0.
+	// Requests wait for a slot of their host and of this limit as one, so
+	// that MaxQueueWait and StarvationTimeout cover the time spent waiting
+	// for both, while MaxQueueLength only limits the queue of each host.
+	MaxConcurrentRequests int
+
+	// AdaptiveLimit enables the adjustment of the concurrency limit of the
+	// pool, or of each host if PerHost is enabled, and of each cluster
+	// endpoint, which is otherwise fixed at PoolSize.
+	AdaptiveLimit *AdaptiveLimit
+
+	// Resolver looks up the endpoints of service base URLs, see
+	// ServiceScheme. Clients for them can not be created if nil.
+	Resolver Resolver
+
+	// Authenticator adds credentials to all requests unless overridden by
+	// WithAuthenticator. If neither is set, credentials in a base URL's
+	// userinfo are sent using BasicAuth.
+	Authenticator Authenticator
+
+	// Signer signs all requests unless overridden by WithSigner.
+	Signer Signer
+
+	// TraceConnections enables the collection of connection diagnostics
+	// for all requests, which are aggregated per base URL in Stats.
+	TraceConnections bool
+
+	// OnTrace is an optional callback receiving the connection diagnostics
+	// of each request and its base URL, setting it enables TraceConnections.
+	OnTrace func(baseURL string, result TraceResult)
+}
+
+// Stats contains a snapshot of a ConnectionPool's metrics.
+type Stats struct {
+	// Compression contains the byte counts of compressed requests
+	// and responses.
+	Compression CompressionStats
+
+	// Connections contains the aggregated connection diagnostics per base
+	// URL if TraceConnections is enabled.
+	Connections map[string]ConnectionStats
+
+	// Queue contains the state of the queues of requests waiting for a
+	// slot, summed over the pool and its cluster endpoints.
+	Queue QueueStats
+
+	// Limit is the current concurrency limit of the pool, which only
+	// differs from PoolSize if AdaptiveLimit is enabled. If PerHost is
+	// enabled it is MaxConcurrentRequests, or 0 if that is unlimited.
+	Limit int
+
+	// Hosts contains the state of each host's limit if PerHost is enabled.
+	Hosts map[string]HostStats
+}
+
+// HTTPOption configures a HTTP client created by a ConnectionPool.
+type HTTPOption func(*httpOptions)
+
+type httpOptions struct {
+	balancer         Balancer
+	healthCheck      *HealthCheck
+	outlierDetection *OutlierDetection
+	hedging          *Hedging
+	coalescing       []string
+	authenticator    Authenticator
+	signer           Signer
+}
+
+// WithBalancer sets the Balancer used by a Cluster to choose endpoints,
+// which defaults to RoundRobin.
+func WithBalancer(balancer Balancer) HTTPOption {
+	return func(options *httpOptions) {
+		options.balancer = balancer
+	}
+}
+
+func newHTTPOptions(options []HTTPOption) *httpOptions {
+	result := &httpOptions{
+		balancer: RoundRobin(),
+	}
+	for _, option := range options {
+		option(result)
+	}
+	return result
 }
 
 // ConnectionPool holds a fixed set of connections from which
@@ -26,12 +228,96 @@ type Config struct {
 type ConnectionPool interface {
 	// HTTP returns an HTTP client with the given base URL
 	// using the pool's configuration and connections.
-	HTTP(url string) (HTTP, error)
+	//
+	// If the base URL names a service, the client balances requests across
+	// the endpoints of the service as if created by HTTPCluster. The
+	// service is then resolved again in the background until the pool is
+	// shut down, or the client, which is a Cluster, is closed.
+	HTTP(url string, options ...HTTPOption) (HTTP, error)
+
+	// HTTPCluster returns a HTTP client which balances requests across
+	// the given base URLs using the pool's configuration and connections.
+	//
+	// Each endpoint has its own limit of PoolSize concurrent requests,
+	// which is shared by all clusters of the pool including it.
+	//
+	// Requests are authenticated and signed for the endpoint they are sent
+	// to, using the credentials in the userinfo of its base URL unless an
+	// Authenticator is set.
+	//
+	// Base URLs naming a service are replaced by the endpoints of the
+	// service, which are kept up to date using the pool's Resolver until
+	// the cluster is closed. Creating the cluster fails if a service can
+	// not be resolved, or with ErrPoolClosed once the pool has been shut
+	// down.
+	HTTPCluster(urls []string, options ...HTTPOption) (Cluster, error)
+
+	// Stats returns a snapshot of the pool's metrics.
+	Stats() Stats
+
+	// Warm opens idle connections to the hosts of the base URLs of all
+	// clients created from the pool, up to the concurrency limit of each
+	// host, so that the first requests need not wait for connections to
+	// be established. The requests opening them hold a slot at PriorityLow
+	// of the limit of the clients or cluster endpoints using each host, and
+	// redirects are not followed.
+	//
+	// The first error encountered is returned once all connections were
+	// attempted, or the context's error if it is done first.
+	Warm(ctx context.Context) error
+
+	// Shutdown stops the pool from accepting new requests, which fail with
+	// ErrPoolClosed, and waits for requests in flight to finish or for ctx
+	// to be done. It then stops the health checks and service resolution
+	// of the pool's clusters and closes all idle connections.
+	//
+	// The context's error is returned if requests were still in flight.
+	Shutdown(ctx context.Context) error
+
+	// Update applies the PoolSize and SkipSSLValidation of config to the
+	// pool and all clients created from it. All other settings, such as
+	// Pins, Proxy, Timeouts and HTTP2, are ignored and keep the values the
+	// pool was created with.
+	//
+	// Requests in flight are unaffected, if the pool shrinks they keep
+	// their slots until they complete. Connections are made using the new
+	// settings from then on, while existing connections are closed once
+	// they are idle and all requests made before the update have completed.
+	Update(config Config)
 }
 
 type pool struct {
 	Config
-	netHTTPClient
+	client           *http.Client
+	transport        *swappableTransport
+	dialer           *net.Dialer
+	proxy            func(*http.Request) (*url.URL, error)
+	proxyErr         error
+	unixSockets      *unixSockets
+	poolSize         int
+	throttledClient  *throttledHTTPClient
+	upstream         netHTTPClient
+	compressionStats *compressionStats
+	connectionStats  *connectionStats
+	queueStats       *queueStats
+	lifecycle        *lifecycle
+
+	// endpointsMutex guards endpoints as well as the settings which may
+	// be changed by Update.
+	endpointsMutex sync.Mutex
+	endpoints      map[string]*endpoint
+	hosts          map[string]*throttledHTTPClient
+	hostRefs       map[string]int
+
+	// origins contains a base URL of each scheme and host which clients
+	// of the pool send requests to, keyed by origin.
+	origins map[string]*originRef
+
+	// clustersMutex guards clusters, which contains the open clusters of
+	// the pool checking the health of their endpoints or resolving
+	// services in the background.
+	clustersMutex sync.Mutex
+	clusters      map[*clusterClient]nothing
 }
 
 // NewConnectionPool creates a new ConnectionPool using the provided
@@ -42,15 +328,302 @@ func NewConnectionPool(config Config) ConnectionPool {
 		poolSize = DefaultPoolSize
 	}
 
-	return &pool{
-		Config: config,
-		netHTTPClient: newThrottledHTTPClient(&http.Client{
-			Transport: &http.Transport{
-				MaxIdleConnsPerHost: poolSize,
-				TLSClientConfig:     &tls.Config{InsecureSkipVerify: config.SkipSSLValidation},
-			},
-		}, poolSize),
+	if config.MaxDrainSize <= 0 {
+		config.MaxDrainSize = DefaultMaxDrainSize
+	}
+
+	if config.TCPKeepAlive == 0 {
+		config.TCPKeepAlive = DefaultTCPKeepAlive
+	}
+
+	switch {
+	case config.IdleConnTimeout == 0:
+		config.IdleConnTimeout = DefaultIdleConnTimeout
+	case config.IdleConnTimeout < 0:
+		config.IdleConnTimeout = 0
+	}
+
+	if config.AdaptiveLimit != nil {
+		adaptiveLimit := *config.AdaptiveLimit
+		if adaptiveLimit.Min <= 0 {
+			adaptiveLimit.Min = 1
+		}
+		if adaptiveLimit.Max <= 0 {
+			adaptiveLimit.Max = poolSize
+		}
+		if adaptiveLimit.Algorithm == nil {
+			adaptiveLimit.Algorithm = AIMD(0)
+		}
+		config.AdaptiveLimit = &adaptiveLimit
+	}
+
+	pool := &pool{
+		Config:           config,
+		poolSize:         poolSize,
+		compressionStats: new(compressionStats),
+		connectionStats:  newConnectionStats(),
+		queueStats:       new(queueStats),
+		lifecycle:        newLifecycle(),
+		dialer:           &net.Dialer{Timeout: 30 * time.Second, KeepAlive: config.TCPKeepAlive},
+		endpoints:        make(map[string]*endpoint),
+		hostRefs:         make(map[string]int),
+		origins:          make(map[string]*originRef),
+		hosts:            make(map[string]*throttledHTTPClient),
+		clusters:         make(map[*clusterClient]nothing),
+	}
+	pool.unixSockets = &unixSockets{paths: make(map[string]string)}
+	if config.Proxy != nil && config.Proxy.URL != "" {
+		_, pool.proxyErr = parseProxyURL(config.Proxy.URL)
+	}
+	if proxy := newProxyFunc(config.Proxy); proxy != nil {
+		pool.proxy = func(req *http.Request) (*url.URL, error) {
+			if _, ok := pool.unixSockets.path(req.URL.Host); ok {
+				return nil, nil
+			}
+			return proxy(req)
+		}
+	}
+	pool.transport = newSwappableTransport(pool.newTransport())
+	pool.client = &http.Client{Transport: pool.transport}
+	pool.upstream = &timeoutHTTPClient{netHTTPClient: pool.client, timeouts: config.Timeouts}
+	if !config.PerHost {
+		pool.throttledClient = pool.throttled(pool.upstream, poolSize)
+	} else if config.MaxConcurrentRequests > 0 {
+		// A slot of the global limit is acquired along with each slot of a
+		// host, whose queue is the only one limited.
+		pool.throttledClient = &throttledHTTPClient{
+			semaphore:     pool.newSemaphore(config.MaxConcurrentRequests),
+			netHTTPClient: pool.upstream,
+		}
+		pool.throttledClient.maxWaiting = 0
+	}
+	return pool
+}
+
+// newTransport creates a transport using the pool's current settings.
+func (pool *pool) newTransport() *http.Transport {
+	transport := &http.Transport{
+		Proxy:               pool.proxy,
+		DialContext:         pool.dial,
+		MaxIdleConns:        max(pool.MaxIdleConns, 0),
+		MaxIdleConnsPerHost: pool.maxIdleConnsPerHost(),
+		IdleConnTimeout:     pool.IdleConnTimeout,
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: pool.SkipSSLValidation,
+			VerifyConnection:   verifyPins(pool.Pins),
+		},
+	}
+	pool.HTTP2.configure(transport)
+	return transport
+}
+
+// maxIdleConnsPerHost returns the number of idle connections kept to each
+// host, which defaults to the pool's largest concurrency limit.
+func (pool *pool) maxIdleConnsPerHost() int {
+	if pool.MaxIdleConnsPerHost > 0 {
+		return pool.MaxIdleConnsPerHost
+	}
+
+	maxIdleConns := pool.poolSize
+	if pool.PerHost {
+		for _, size := range pool.HostPoolSizes {
+			if size > maxIdleConns {
+				maxIdleConns = size
+			}
+		}
+	}
+	if pool.AdaptiveLimit != nil {
+		maxIdleConns = pool.AdaptiveLimit.Max
+	}
+	return maxIdleConns
+}
+
+// throttled returns client limited to size concurrent requests,
+// or an adaptive limit if enabled.
+func (pool *pool) throttled(client netHTTPClient, size int) *throttledHTTPClient {
+	semaphore := pool.newSemaphore(size)
+	throttledClient := &throttledHTTPClient{
+		semaphore:     semaphore,
+		netHTTPClient: client,
+	}
+	if adaptiveLimit := pool.AdaptiveLimit; adaptiveLimit != nil {
+		semaphore.limit = clamp(size, adaptiveLimit.Min, adaptiveLimit.Max)
+		throttledClient.limiter = &adaptiveLimiter{
+			algorithm: adaptiveLimit.Algorithm(),
+			min:       adaptiveLimit.Min,
+			max:       adaptiveLimit.Max,
+			semaphore: semaphore,
+		}
+	}
+	return throttledClient
+}
+
+// newSemaphore returns a semaphore of limit slots which uses the pool's
+// reservations and queueing settings.
+func (pool *pool) newSemaphore(limit int) *semaphore {
+	semaphore := newSemaphore(limit)
+	for priority, reserved := range pool.ReservedSlots {
+		semaphore.reserved[priority.index()] += reserved
+	}
+	semaphore.starvationTimeout = pool.StarvationTimeout
+	if semaphore.starvationTimeout == 0 {
+		semaphore.starvationTimeout = DefaultStarvationTimeout
+	}
+	semaphore.maxWaiting = pool.MaxQueueLength
+	semaphore.maxWait = pool.MaxQueueWait
+	semaphore.stats = pool.queueStats
+	return semaphore
+}
+
+func (pool *pool) compressing(client netHTTPClient) netHTTPClient {
+	return newCompressingHTTPClient(client, pool.RequestCompression, pool.MaxDrainSize, pool.compressionStats)
+}
+
+func (pool *pool) hedging(client netHTTPClient, options *httpOptions) netHTTPClient {
+	if options.hedging == nil {
+		return client
+	}
+	return newHedgingHTTPClient(client, *options.hedging)
+}
+
+// authenticate adds the authenticator of client to its requests, moving
+// any credentials out of its base URL.
+func (pool *pool) authenticate(client *httpClient, options *httpOptions) {
+	client.netHTTPClient = pool.authenticating(client.netHTTPClient, client.URL, options)
+	client.URL = withoutUserinfo(client.URL)
+}
+
+// authenticating returns client adding credentials to requests to baseURL,
+// using its userinfo unless an authenticator is configured.
+func (pool *pool) authenticating(client netHTTPClient, baseURL *url.URL, options *httpOptions) netHTTPClient {
+	authenticator := options.authenticator
+	if authenticator == nil {
+		authenticator = pool.Authenticator
+	}
+	if user := baseURL.User; user != nil && authenticator == nil {
+		password, _ := user.Password()
+		authenticator = BasicAuth(user.Username(), password)
+	}
+	if authenticator == nil {
+		return client
+	}
+
+	return &authenticatingHTTPClient{
+		netHTTPClient: client,
+		authenticator: authenticator,
+		maxDrainSize:  pool.MaxDrainSize,
+	}
+}
+
+// withoutUserinfo returns baseURL without any credentials.
+func withoutUserinfo(baseURL *url.URL) *url.URL {
+	if baseURL.User == nil {
+		return baseURL
+	}
+	stripped := *baseURL
+	stripped.User = nil
+	return &stripped
+}
+
+func (pool *pool) signer(options *httpOptions) Signer {
+	if options.signer != nil {
+		return options.signer
 	}
+	return pool.Signer
+}
+
+func (pool *pool) signing(client netHTTPClient, options *httpOptions) netHTTPClient {
+	signer := pool.signer(options)
+	if signer == nil {
+		return client
+	}
+	return &signingHTTPClient{netHTTPClient: client, signer: signer}
+}
+
+func (pool *pool) coalescing(client netHTTPClient, options *httpOptions) netHTTPClient {
+	if options.coalescing == nil {
+		return client
+	}
+	return newCoalescingHTTPClient(client, options.coalescing, pool.MaxResponseSize)
+}
+
+func (pool *pool) tracing(client netHTTPClient, baseURL *url.URL) netHTTPClient {
+	return newTracingHTTPClient(client, baseURL.String(), pool.TraceConnections, pool.OnTrace, pool.connectionStats)
+}
+
+// newHTTP creates a HTTP client for baseURL which sends requests
+// using client.
+func (pool *pool) newHTTP(baseURL string, client netHTTPClient) (*httpClient, error) {
+	if pool.proxyErr != nil {
+		return nil, pool.proxyErr
+	}
+	baseURL, err := pool.unixSockets.baseURL(baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	http, err := newHTTP(baseURL, client)
+	if err != nil {
+		return nil, err
+	}
+
+	result := http.(*httpClient)
+	result.maxDrainSize = pool.MaxDrainSize
+	result.maxResponseSize = pool.MaxResponseSize
+	result.lifecycle = pool.lifecycle
+	result.totalTimeout = pool.Timeouts.Total
+	result.multiplexed = pool.HTTP2.multiplexed()
+	return result, nil
+}
+
+// endpoint returns the endpoint for baseURL, creating it if required. Each
+// endpoint returned must be released once it is no longer used.
+func (pool *pool) endpoint(baseURL *url.URL) *endpoint {
+	pool.endpointsMutex.Lock()
+	defer pool.endpointsMutex.Unlock()
+
+	key := baseURL.String()
+	if pool.endpoints[key] == nil {
+		var throttledClient *throttledHTTPClient
+		if pool.PerHost {
+			throttledClient = pool.host(baseURL.Host)
+		} else {
+			throttledClient = pool.throttled(pool.upstream, pool.poolSize)
+		}
+		pool.addOrigin(baseURL, throttledClient)
+		pool.endpoints[key] = &endpoint{
+			URL:             baseURL,
+			netHTTPClient:   pool.tracing(throttledClient, baseURL),
+			throttledClient: throttledClient,
+		}
+	}
+	pool.endpoints[key].refs++
+	return pool.endpoints[key]
+}
+
+// releaseEndpoints releases the endpoints of a cluster.
+func (pool *pool) releaseEndpoints(endpoints []*clusterEndpoint) {
+	for _, endpoint := range endpoints {
+		pool.releaseEndpoint(endpoint.endpoint)
+	}
+}
+
+// releaseEndpoint releases a reference to endpoint, forgetting it along with
+// its host, origin and stats once it is no longer used.
+func (pool *pool) releaseEndpoint(endpoint *endpoint) {
+	pool.endpointsMutex.Lock()
+	defer pool.endpointsMutex.Unlock()
+
+	key := endpoint.URL.String()
+	if endpoint.refs--; endpoint.refs > 0 || pool.endpoints[key] != endpoint {
+		return
+	}
+	delete(pool.endpoints, key)
+	pool.releaseOrigin(endpoint.URL, endpoint.throttledClient)
+	if pool.PerHost {
+		pool.releaseHost(endpoint.URL.Host)
+	}
+	pool.connectionStats.forget(key)
 }
 
 // NewHTTP creates a HTTP instance for the given baseURL with its own
@@ -59,6 +632,184 @@ func NewHTTP(baseURL string, config Config) (HTTP, error) {
 	return NewConnectionPool(config).HTTP(baseURL)
 }
 
-func (pool *pool) HTTP(baseURL string) (HTTP, error) {
-	return newHTTP(baseURL, pool.netHTTPClient)
+func (pool *pool) HTTP(baseURL string, options ...HTTPOption) (HTTP, error) {
+	if strings.HasPrefix(baseURL, ServiceScheme+"://") {
+		cluster, err := pool.HTTPCluster([]string{baseURL}, options...)
+		if err != nil {
+			return nil, err
+		}
+		return cluster, nil
+	}
+
+	client, err := pool.newHTTP(baseURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	limited := pool.throttledClient
+	pool.endpointsMutex.Lock()
+	if pool.PerHost {
+		limited = pool.host(client.URL.Host)
+	}
+	pool.addOrigin(client.URL, limited)
+	pool.endpointsMutex.Unlock()
+
+	httpOptions := newHTTPOptions(options)
+	hedging := pool.hedging(pool.signing(limited, httpOptions), httpOptions)
+	client.netHTTPClient = pool.compressing(hedging)
+	pool.authenticate(client, httpOptions)
+	client.netHTTPClient = pool.coalescing(pool.tracing(client.netHTTPClient, client.URL), httpOptions)
+	return client, nil
+}
+
+func (pool *pool) HTTPCluster(baseURLs []string, options ...HTTPOption) (Cluster, error) {
+	if len(baseURLs) == 0 {
+		return nil, errors.New("At least one base URL is required")
+	}
+
+	httpOptions := newHTTPOptions(options)
+	balancer := &balancingHTTPClient{
+		balancer:         httpOptions.balancer,
+		outlierDetection: httpOptions.outlierDetection,
+		endpointClient: func(endpoint *endpoint) netHTTPClient {
+			signing := pool.signing(endpoint, httpOptions)
+			return pool.authenticating(pool.compressing(signing), endpoint.URL, httpOptions)
+		},
+	}
+	var services []*service
+	for _, baseURL := range baseURLs {
+		service, placeholder, err := parseServiceURL(baseURL)
+		if err != nil {
+			return nil, err
+		}
+		if service != nil {
+			services = append(services, service)
+			if balancer.baseURL == nil {
+				balancer.baseURL = placeholder
+			}
+			continue
+		}
+
+		parsed, err := pool.parseEndpointURL(baseURL)
+		if err != nil {
+			pool.releaseEndpoints(balancer.endpoints)
+			return nil, err
+		}
+		endpoint := balancer.newEndpoint(pool.endpoint(parsed))
+		balancer.endpoints = append(balancer.endpoints, endpoint)
+		if balancer.baseURL == nil {
+			balancer.baseURL = endpoint.URL
+		}
+	}
+
+	client, err := pool.newHTTP(balancer.baseURL.String(), pool.hedging(balancer, httpOptions))
+	if err != nil {
+		pool.releaseEndpoints(balancer.endpoints)
+		return nil, err
+	}
+	client.URL = withoutUserinfo(client.URL)
+	client.netHTTPClient = pool.coalescing(client.netHTTPClient, httpOptions)
+
+	cluster := &clusterClient{httpClient: client, pool: pool, balancer: balancer}
+	if services != nil {
+		if cluster.serviceResolver, err = newServiceResolver(pool, balancer, services); err != nil {
+			pool.releaseEndpoints(balancer.current())
+			return nil, err
+		}
+	}
+
+	if err := pool.addCluster(cluster, httpOptions.healthCheck); err != nil {
+		cluster.Close()
+		return nil, err
+	}
+	return cluster, nil
+}
+
+// addCluster starts the health checks of cluster if required, and keeps
+// track of it until closed so that its background work is stopped on
+// Shutdown. It fails with ErrPoolClosed once the pool has been shut down.
+func (pool *pool) addCluster(cluster *clusterClient, healthCheck *HealthCheck) error {
+	pool.clustersMutex.Lock()
+	defer pool.clustersMutex.Unlock()
+
+	if pool.lifecycle.isClosed() {
+		return ErrPoolClosed
+	}
+	if healthCheck != nil {
+		cluster.healthChecker = newHealthChecker(*healthCheck, cluster.balancer.current)
+	}
+	if cluster.healthChecker != nil || cluster.serviceResolver != nil {
+		pool.clusters[cluster] = nothing{}
+	}
+	return nil
+}
+
+// removeCluster stops keeping track of a closed cluster.
+func (pool *pool) removeCluster(cluster *clusterClient) {
+	pool.clustersMutex.Lock()
+	defer pool.clustersMutex.Unlock()
+	delete(pool.clusters, cluster)
+}
+
+// parseEndpointURL parses the base URL of a cluster endpoint, registering
+// the socket of Unix socket base URLs.
+func (pool *pool) parseEndpointURL(baseURL string) (*url.URL, error) {
+	baseURL, err := pool.unixSockets.baseURL(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	return parseBaseURL(baseURL)
+}
+
+func (pool *pool) Stats() Stats {
+	stats := Stats{
+		Compression: pool.compressionStats.snapshot(),
+		Connections: pool.connectionStats.snapshot(),
+		Queue:       pool.queueStats.snapshot(),
+	}
+	if pool.throttledClient != nil {
+		stats.Limit = pool.throttledClient.Limit()
+	}
+	if pool.PerHost {
+		stats.Hosts = pool.hostStats()
+	}
+	return stats
+}
+
+func (pool *pool) Shutdown(ctx context.Context) error {
+	err := pool.lifecycle.close(ctx)
+
+	pool.clustersMutex.Lock()
+	for cluster := range pool.clusters {
+		cluster.stop()
+	}
+	pool.clustersMutex.Unlock()
+
+	pool.client.CloseIdleConnections()
+	return err
+}
+
+func (pool *pool) Update(config Config) {
+	poolSize := config.PoolSize
+	if poolSize <= 0 {
+		poolSize = DefaultPoolSize
+	}
+
+	pool.endpointsMutex.Lock()
+	defer pool.endpointsMutex.Unlock()
+
+	pool.poolSize = poolSize
+	pool.SkipSSLValidation = config.SkipSSLValidation
+
+	if pool.PerHost {
+		for host, throttledClient := range pool.hosts {
+			throttledClient.resize(pool.hostPoolSize(host))
+		}
+	} else {
+		pool.throttledClient.resize(poolSize)
+		for _, endpoint := range pool.endpoints {
+			endpoint.throttledClient.resize(poolSize)
+		}
+	}
+	pool.transport.swap(pool.newTransport())
 }
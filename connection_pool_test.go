@@ -0,0 +1,261 @@
+package sling_test
+
+import (
+	"context"
+	"golang.struktur.de/sling"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestConnectionPool_UpdateResizesThePool(t *testing.T) {
+	pool := sling.NewConnectionPool(sling.Config{PoolSize: 2})
+
+	pool.Update(sling.Config{PoolSize: 5})
+
+	if limit := pool.Stats().Limit; limit != 5 {
+		t.Errorf("Expected limit to be %d after update, but was %d", 5, limit)
+	}
+}
+
+func TestConnectionPool_UpdateAppliesTLSSettingsToExistingClients(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	pool := sling.NewConnectionPool(sling.Config{})
+	client, _ := pool.HTTP(server.URL)
+
+	if err := client.Do(sling.JSONRequest("GET", "")); err == nil {
+		t.Fatal("Expected request to fail validating the server's certificate")
+	}
+
+	pool.Update(sling.Config{SkipSSLValidation: true})
+
+	if err := client.Do(sling.JSONRequest("GET", "")); err != nil {
+		t.Errorf("Unexpected error after update: %v", err)
+	}
+}
+
+func TestConnectionPool_UpdateIsSafeDuringRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	pool := sling.NewConnectionPool(sling.Config{PoolSize: 1})
+	client, _ := pool.HTTP(server.URL)
+	cluster, _ := pool.HTTPCluster([]string{server.URL})
+
+	requests := &sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		requests.Add(1)
+		go func(client sling.HTTP) {
+			defer requests.Done()
+			for j := 0; j < 10; j++ {
+				if err := client.Do(sling.JSONRequest("GET", "")); err != nil {
+					t.Errorf("Unexpected error during update: %v", err)
+				}
+			}
+		}([]sling.HTTP{client, cluster}[i%2])
+	}
+
+	for size := 1; size <= 4; size++ {
+		pool.Update(sling.Config{PoolSize: size})
+	}
+	requests.Wait()
+
+	if limit := pool.Stats().Limit; limit != 4 {
+		t.Errorf("Expected limit to be %d after updates, but was %d", 4, limit)
+	}
+}
+
+func TestConnectionPool_UpdateClosesConnectionsOnceRequestsComplete(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	var closed int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		w.Write([]byte("{}"))
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateClosed {
+			atomic.AddInt32(&closed, 1)
+		}
+	}
+	server.Config.Protocols = new(http.Protocols)
+	server.Config.Protocols.SetUnencryptedHTTP2(true)
+	server.Start()
+	defer server.Close()
+
+	// Unlike HTTP/1.1 transports, HTTP/2 transports keep connections
+	// which only become idle after closing idle connections.
+	pool := sling.NewConnectionPool(sling.Config{IdleConnTimeout: -1, HTTP2: sling.HTTP2{Mode: sling.HTTP2Only}})
+	client, _ := pool.HTTP(server.URL)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- client.Do(sling.JSONRequest("GET", ""))
+	}()
+	<-started
+	pool.Update(sling.Config{})
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&closed) != 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if count := atomic.LoadInt32(&closed); count != 1 {
+		t.Errorf("Expected the connection of the previous transport to have been closed, but %d were", count)
+	}
+}
+
+func TestConnectionPool_PerHostLimitsDoNotStarveOtherHosts(t *testing.T) {
+	slow, started, release := newBlockingServer()
+	defer slow.Close()
+	defer close(release)
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	}))
+	defer fast.Close()
+
+	pool := sling.NewConnectionPool(sling.Config{PoolSize: 1, PerHost: true})
+	slowClient, _ := pool.HTTP(slow.URL)
+	fastClient, _ := pool.HTTP(fast.URL)
+
+	go slowClient.Do(sling.JSONRequest("GET", ""))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := fastClient.Do(sling.JSONRequest("GET", "").Context(ctx)); err != nil {
+		t.Errorf("Unexpected error for a request to another host: %v", err)
+	}
+
+	slowHost := strings.TrimPrefix(slow.URL, "http://")
+	expected := sling.HostStats{Limit: 1, InFlight: 1}
+	if stats := pool.Stats().Hosts[slowHost]; stats != expected {
+		t.Errorf("Expected stats of %s to be %+v, but were %+v", slowHost, expected, stats)
+	}
+}
+
+func TestConnectionPool_HostPoolSizesOverridePoolSize(t *testing.T) {
+	pool := sling.NewConnectionPool(sling.Config{
+		PoolSize:      2,
+		PerHost:       true,
+		HostPoolSizes: map[string]int{"b.example.com": 5},
+	})
+	pool.HTTP("http://a.example.com")
+	pool.HTTPCluster([]string{"http://b.example.com/v1", "http://b.example.com/v2"})
+
+	hosts := pool.Stats().Hosts
+	if len(hosts) != 2 {
+		t.Fatalf("Expected stats for %d hosts, but got %v", 2, hosts)
+	}
+	if limit := hosts["a.example.com"].Limit; limit != 2 {
+		t.Errorf("Expected limit of %s to be %d, but was %d", "a.example.com", 2, limit)
+	}
+	if limit := hosts["b.example.com"].Limit; limit != 5 {
+		t.Errorf("Expected limit of %s to be %d, but was %d", "b.example.com", 5, limit)
+	}
+}
+
+func TestConnectionPool_MaxConcurrentRequestsLimitsAllHosts(t *testing.T) {
+	slow, started, release := newBlockingServer()
+	defer slow.Close()
+	defer close(release)
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	}))
+	defer fast.Close()
+
+	pool := sling.NewConnectionPool(sling.Config{PerHost: true, MaxConcurrentRequests: 1})
+	slowClient, _ := pool.HTTP(slow.URL)
+	fastClient, _ := pool.HTTP(fast.URL)
+
+	go slowClient.Do(sling.JSONRequest("GET", ""))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := fastClient.Do(sling.JSONRequest("GET", "").Context(ctx)); err != context.DeadlineExceeded {
+		t.Errorf("Expected request to fail with %v, but got %v", context.DeadlineExceeded, err)
+	}
+	if limit := pool.Stats().Limit; limit != 1 {
+		t.Errorf("Expected pool limit to be %d, but was %d", 1, limit)
+	}
+}
+
+func TestConnectionPool_RequestsWaitForHostAndGlobalSlotsOnce(t *testing.T) {
+	slow, started, release := newBlockingServer()
+	defer slow.Close()
+	defer close(release)
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	}))
+	defer fast.Close()
+
+	pool := sling.NewConnectionPool(sling.Config{
+		PerHost:               true,
+		MaxConcurrentRequests: 1,
+		HostPoolSizes:         map[string]int{fast.Listener.Addr().String(): 1},
+		MaxQueueWait:          100 * time.Millisecond,
+	})
+	slowClient, _ := pool.HTTP(slow.URL)
+	fastClient, _ := pool.HTTP(fast.URL)
+
+	go slowClient.Do(sling.JSONRequest("GET", ""))
+	<-started
+	go fastClient.Do(sling.JSONRequest("GET", ""))
+	time.Sleep(20 * time.Millisecond)
+
+	start := time.Now()
+	if err := fastClient.Do(sling.JSONRequest("GET", "")); err != sling.ErrPoolSaturated {
+		t.Errorf("Expected request to fail with %v, but got %v", sling.ErrPoolSaturated, err)
+	}
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+		t.Errorf("Expected the maximum wait to apply once to both limits, but waited %v", elapsed)
+	}
+}
+
+func TestConnectionPool_QueueLengthIsOnlyLimitedPerHost(t *testing.T) {
+	slow, started, release := newBlockingServer()
+	defer slow.Close()
+	first, second := newNamedServer("first"), newNamedServer("second")
+	defer first.Close()
+	defer second.Close()
+
+	pool := sling.NewConnectionPool(sling.Config{PerHost: true, MaxConcurrentRequests: 1, MaxQueueLength: 1})
+	slowClient, _ := pool.HTTP(slow.URL)
+	go slowClient.Do(sling.JSONRequest("GET", ""))
+	<-started
+
+	errs := make(chan error, 2)
+	for _, server := range []*httptest.Server{first, second} {
+		client, _ := pool.HTTP(server.URL)
+		go func() {
+			errs <- client.Do(sling.JSONRequest("GET", ""))
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("Unexpected error for request waiting for the global limit: %v", err)
+		}
+	}
+	if shed := pool.Stats().Queue.Shed; shed != 0 {
+		t.Errorf("Expected no requests to have been shed, but %d were", shed)
+	}
+}
@@ -0,0 +1,154 @@
+package sling
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Credentials are the secrets used by Authenticators and Signers, which
+// use the fields as documented by each.
+type Credentials struct {
+	// Username is the user name, access key or key ID.
+	Username string
+
+	// Password is the password or secret key.
+	Password string
+
+	// Token is a bearer or session token.
+	Token string
+}
+
+// CredentialProvider implementations supply the current Credentials, they
+// are called for every request and must be safe for concurrent use.
+type CredentialProvider interface {
+	Credentials() (Credentials, error)
+}
+
+// StaticCredentials returns a CredentialProvider which always supplies
+// credentials.
+func StaticCredentials(credentials Credentials) CredentialProvider {
+	return staticCredentials(credentials)
+}
+
+type staticCredentials Credentials
+
+func (credentials staticCredentials) Credentials() (Credentials, error) {
+	return Credentials(credentials), nil
+}
+
+// EnvCredentials returns a CredentialProvider reading each field of the
+// Credentials from the environment variable of the given name, fields
+// whose name is empty are left empty.
+func EnvCredentials(username, password, token string) CredentialProvider {
+	return &envCredentials{username, password, token}
+}
+
+type envCredentials struct {
+	username, password, token string
+}
+
+func (env *envCredentials) Credentials() (Credentials, error) {
+	return Credentials{
+		Username: getenv(env.username),
+		Password: getenv(env.password),
+		Token:    getenv(env.token),
+	}, nil
+}
+
+func getenv(name string) string {
+	if name == "" {
+		return ""
+	}
+	return os.Getenv(name)
+}
+
+// DefaultCredentialFileInterval is the interval at which credential files
+// are checked for changes.
+const DefaultCredentialFileInterval = time.Second
+
+// FileCredentials returns a CredentialProvider reading each field of the
+// Credentials from the file of the given name, fields whose name is empty
+// are left empty. Trailing line breaks are removed from each file.
+//
+// The files are read again once they have changed, which is checked for
+// at most every DefaultCredentialFileInterval. The last credentials read
+// are kept as a whole if any file cannot be read after a change.
+func FileCredentials(username, password, token string) CredentialProvider {
+	return &fileCredentials{
+		files:    [3]credentialFile{{name: username}, {name: password}, {name: token}},
+		interval: DefaultCredentialFileInterval,
+	}
+}
+
+type fileCredentials struct {
+	sync.Mutex
+	files     [3]credentialFile
+	interval  time.Duration
+	checked   time.Time
+	loaded    bool
+	lastError error
+}
+
+// credentialFile is a file containing a single secret, along with the
+// state of the file when it was read.
+type credentialFile struct {
+	name    string
+	value   string
+	modTime time.Time
+	size    int64
+}
+
+func (files *fileCredentials) Credentials() (Credentials, error) {
+	files.Lock()
+	defer files.Unlock()
+
+	now := time.Now()
+	if !files.loaded || now.Sub(files.checked) >= files.interval {
+		files.checked = now
+		files.lastError = files.reload()
+		if files.lastError == nil {
+			files.loaded = true
+		}
+	}
+	if !files.loaded {
+		return Credentials{}, files.lastError
+	}
+
+	return Credentials{
+		Username: files.files[0].value,
+		Password: files.files[1].value,
+		Token:    files.files[2].value,
+	}, nil
+}
+
+// reload reads all files which have changed since they were last read,
+// keeping the previous credentials unless all of them could be read.
+func (files *fileCredentials) reload() error {
+	updated := files.files
+	for i := range updated {
+		file := &updated[i]
+		if file.name == "" {
+			continue
+		}
+
+		info, err := os.Stat(file.name)
+		if err != nil {
+			return err
+		}
+		if files.loaded && info.ModTime().Equal(file.modTime) && info.Size() == file.size {
+			continue
+		}
+
+		contents, err := ioutil.ReadFile(file.name)
+		if err != nil {
+			return err
+		}
+		file.value = strings.TrimRight(string(contents), "\r\n")
+		file.modTime, file.size = info.ModTime(), info.Size()
+	}
+	files.files = updated
+	return nil
+}
@@ -0,0 +1,86 @@
+package sling
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEnvCredentials_AreReadFromTheEnvironment(t *testing.T) {
+	os.Setenv("SLING_TEST_USERNAME", "user")
+	os.Setenv("SLING_TEST_PASSWORD", "secret")
+	defer os.Unsetenv("SLING_TEST_USERNAME")
+	defer os.Unsetenv("SLING_TEST_PASSWORD")
+
+	credentials, _ := EnvCredentials("SLING_TEST_USERNAME", "SLING_TEST_PASSWORD", "").Credentials()
+	if expected := (Credentials{Username: "user", Password: "secret"}); credentials != expected {
+		t.Errorf("Expected credentials %+v, but got %+v", expected, credentials)
+	}
+}
+
+func TestFileCredentials_AreReloadedOnceChanged(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "sling")
+	defer os.RemoveAll(dir)
+	token := filepath.Join(dir, "token")
+	ioutil.WriteFile(token, []byte("first\n"), 0600)
+
+	provider := FileCredentials("", "", token).(*fileCredentials)
+	provider.interval = 0
+
+	if credentials, err := provider.Credentials(); err != nil || credentials.Token != "first" {
+		t.Fatalf("Expected token %q, but got %q (%v)", "first", credentials.Token, err)
+	}
+
+	ioutil.WriteFile(token, []byte("second-token\n"), 0600)
+	if credentials, _ := provider.Credentials(); credentials.Token != "second-token" {
+		t.Errorf("Expected rotated token %q, but got %q", "second-token", credentials.Token)
+	}
+
+	os.Remove(token)
+	if credentials, err := provider.Credentials(); err != nil || credentials.Token != "second-token" {
+		t.Errorf("Expected the last token %q to be kept, but got %q (%v)", "second-token", credentials.Token, err)
+	}
+}
+
+func TestFileCredentials_PartialChangesAreNotApplied(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "sling")
+	defer os.RemoveAll(dir)
+	username, password := filepath.Join(dir, "username"), filepath.Join(dir, "password")
+	ioutil.WriteFile(username, []byte("first"), 0600)
+	ioutil.WriteFile(password, []byte("first-secret"), 0600)
+
+	provider := FileCredentials(username, password, "").(*fileCredentials)
+	provider.interval = 0
+	provider.Credentials()
+
+	ioutil.WriteFile(username, []byte("second"), 0600)
+	os.Remove(password)
+	expected := Credentials{Username: "first", Password: "first-secret"}
+	if credentials, err := provider.Credentials(); err != nil || credentials != expected {
+		t.Errorf("Expected the previous credentials %+v to be kept, but got %+v (%v)", expected, credentials, err)
+	}
+}
+
+func TestFileCredentials_MissingFilesFail(t *testing.T) {
+	if _, err := FileCredentials("/nonexistent/sling/username", "", "").Credentials(); err == nil {
+		t.Error("Expected reading credentials from a missing file to fail")
+	}
+}
+
+func TestFileCredentials_ChangesAreCheckedForPeriodically(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "sling")
+	defer os.RemoveAll(dir)
+	password := filepath.Join(dir, "password")
+	ioutil.WriteFile(password, []byte("first"), 0600)
+
+	provider := FileCredentials("", password, "").(*fileCredentials)
+	provider.interval = time.Hour
+	provider.Credentials()
+
+	ioutil.WriteFile(password, []byte("second"), 0600)
+	if credentials, _ := provider.Credentials(); credentials.Password != "first" {
+		t.Errorf("Expected password %q until the next check, but got %q", "first", credentials.Password)
+	}
+}
@@ -1,7 +1,8 @@
 // Package sling provides a connection limited HTTP client which ensures that
-// HTTP keep-alive is used whenever possible.
+// HTTP keep-alive is used whenever possible. HTTP/2, including h2c with prior
+// knowledge, may be enabled using Config.HTTP2.
 //
 // Additionally, it provides a fluent DSL for constructing HTTP requests with
-// JSON bodies and processing JSON responses, as well as flexible handling
-// of HTTP status to error translation.
+// JSON or multipart/form-data bodies and processing JSON responses, as well
+// as flexible handling of HTTP status to error translation.
 package sling
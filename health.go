@@ -0,0 +1,256 @@
+package sling
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// DefaultHealthCheckInterval is the default interval between health probes.
+const DefaultHealthCheckInterval = 10 * time.Second
+
+// HealthCheck configures the periodic probing of a Cluster's endpoints,
+// endpoints whose probe fails are not used until a later probe succeeds.
+//
+// Probes are authenticated and signed like any other request of the
+// Cluster.
+type HealthCheck struct {
+	// Method and Path are used to create the JSONRequest probing each
+	// endpoint, Method defaults to GET.
+	Method, Path string
+
+	// Header contains additional headers sent with each probe.
+	Header http.Header
+
+	// ExpectedStatus is the HTTP status of a healthy endpoint's response,
+	// defaults to http.StatusOK.
+	ExpectedStatus int
+
+	// Interval is the time between probes, defaults to
+	// DefaultHealthCheckInterval if less than or equal to 0.
+	Interval time.Duration
+
+	// Timeout is the time after which a probe fails, defaults to Interval
+	// if less than or equal to 0.
+	Timeout time.Duration
+}
+
+// OutlierDetection configures the passive detection of failing endpoints
+// of a Cluster from the responses to its requests.
+//
+// Endpoints are ejected for Backoff once they have failed a number of
+// consecutive requests, with the backoff doubling for each further
+// ejection until a request succeeds. Responses with a 5XX status,
+// transport errors and requests exceeding their Timeouts are considered
+// failures, but not requests failing before being sent, such as those
+// shed by the pool or lacking credentials.
+type OutlierDetection struct {
+	// ConsecutiveFailures is the number of failures after which an
+	// endpoint is ejected, defaults to 5 if less than or equal to 0.
+	ConsecutiveFailures int
+
+	// Backoff is the initial ejection time, defaults to 30 seconds if
+	// less than or equal to 0.
+	Backoff time.Duration
+
+	// MaxBackoff is the maximum ejection time, defaults to 10 times
+	// Backoff if less than Backoff.
+	MaxBackoff time.Duration
+}
+
+// WithHealthCheck enables periodic health probes for a Cluster, which
+// run until the Cluster is closed.
+func WithHealthCheck(check HealthCheck) HTTPOption {
+	if check.Method == "" {
+		check.Method = "GET"
+	}
+	if check.ExpectedStatus == 0 {
+		check.ExpectedStatus = http.StatusOK
+	}
+	if check.Interval <= 0 {
+		check.Interval = DefaultHealthCheckInterval
+	}
+	if check.Timeout <= 0 {
+		check.Timeout = check.Interval
+	}
+
+	return func(options *httpOptions) {
+		options.healthCheck = &check
+	}
+}
+
+// WithOutlierDetection enables the ejection of failing endpoints
+// for a Cluster.
+func WithOutlierDetection(detection OutlierDetection) HTTPOption {
+	if detection.ConsecutiveFailures <= 0 {
+		detection.ConsecutiveFailures = 5
+	}
+	if detection.Backoff <= 0 {
+		detection.Backoff = 30 * time.Second
+	}
+	if detection.MaxBackoff < detection.Backoff {
+		detection.MaxBackoff = 10 * detection.Backoff
+	}
+
+	return func(options *httpOptions) {
+		options.outlierDetection = &detection
+	}
+}
+
+// endpointHealth tracks the health of an endpoint within a cluster.
+type endpointHealth struct {
+	sync.Mutex
+	probeFailed         bool
+	consecutiveFailures int
+	ejections           int
+	ejectedUntil        time.Time
+}
+
+func (health *endpointHealth) available(now time.Time) bool {
+	health.Lock()
+	defer health.Unlock()
+	return !health.probeFailed && !now.Before(health.ejectedUntil)
+}
+
+func (health *endpointHealth) setProbeFailed(failed bool) {
+	health.Lock()
+	defer health.Unlock()
+	health.probeFailed = failed
+}
+
+func (health *endpointHealth) record(failed bool, detection *OutlierDetection, now time.Time) {
+	health.Lock()
+	defer health.Unlock()
+
+	if !failed {
+		health.consecutiveFailures = 0
+		if !now.Before(health.ejectedUntil) {
+			health.ejections = 0
+		}
+		return
+	}
+
+	health.consecutiveFailures++
+	if health.consecutiveFailures < detection.ConsecutiveFailures {
+		return
+	}
+
+	backoff := detection.Backoff << uint(health.ejections)
+	if backoff > detection.MaxBackoff || backoff <= 0 {
+		backoff = detection.MaxBackoff
+	}
+	health.ejectedUntil = now.Add(backoff)
+	health.ejections++
+	health.consecutiveFailures = 0
+}
+
+func (health *endpointHealth) addStatus(status *EndpointStatus, now time.Time) {
+	health.Lock()
+	defer health.Unlock()
+	status.Healthy = !health.probeFailed && !now.Before(health.ejectedUntil)
+	status.ConsecutiveFailures = health.consecutiveFailures
+	if now.Before(health.ejectedUntil) {
+		status.EjectedUntil = health.ejectedUntil
+	}
+}
+
+func isFailure(req *http.Request, response *http.Response, err error) bool {
+	if err != nil {
+		// Requests shed by the pool, or failing to be authenticated or
+		// signed, were never sent and say nothing about the endpoint's
+		// health. Neither do requests cancelled by the caller, unlike
+		// those exceeding the Total timeout which is applied to the
+		// request's context.
+		if errors.Is(err, ErrPoolSaturated) {
+			return false
+		}
+		var timeout *ErrTimeout
+		if errors.As(context.Cause(req.Context()), &timeout) {
+			return true
+		}
+		var roundTrip *url.Error
+		return errors.As(err, &roundTrip) && req.Context().Err() == nil
+	}
+	return response.StatusCode >= http.StatusInternalServerError
+}
+
+// healthChecker periodically probes endpoints until stopped.
+type healthChecker struct {
+	HealthCheck
+	endpoints func() []*clusterEndpoint
+	stop      chan struct{}
+	stopOnce  sync.Once
+}
+
+// newHealthChecker starts probing the endpoints returned by endpoints.
+func newHealthChecker(check HealthCheck, endpoints func() []*clusterEndpoint) *healthChecker {
+	checker := &healthChecker{
+		HealthCheck: check,
+		endpoints:   endpoints,
+		stop:        make(chan struct{}),
+	}
+	go checker.run()
+	return checker
+}
+
+func (checker *healthChecker) Stop() {
+	checker.stopOnce.Do(func() {
+		close(checker.stop)
+	})
+}
+
+func (checker *healthChecker) run() {
+	ticker := time.NewTicker(checker.Interval)
+	defer ticker.Stop()
+
+	for {
+		checker.probeAll()
+		select {
+		case <-ticker.C:
+		case <-checker.stop:
+			return
+		}
+	}
+}
+
+func (checker *healthChecker) probeAll() {
+	probes := &sync.WaitGroup{}
+	for _, endpoint := range checker.endpoints() {
+		probes.Add(1)
+		go func(endpoint *clusterEndpoint) {
+			defer probes.Done()
+			endpoint.health.setProbeFailed(!checker.probe(endpoint))
+		}(endpoint)
+	}
+	probes.Wait()
+}
+
+func (checker *healthChecker) probe(endpoint *clusterEndpoint) bool {
+	request := JSONRequest(checker.Method, checker.Path)
+	for name, values := range checker.Header {
+		for _, value := range values {
+			request.Header(name, value)
+		}
+	}
+	req, _, err := request.HTTPRequest(withoutUserinfo(endpoint.URL))
+	if err != nil {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), checker.Timeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-checker.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	response, err := endpoint.client.Do(req.WithContext(ctx))
+	defer closeResponse(response, DefaultMaxDrainSize)
+	return err == nil && response.StatusCode == checker.ExpectedStatus
+}
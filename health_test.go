@@ -0,0 +1,212 @@
+package sling_test
+
+import (
+	"golang.struktur.de/sling"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newStatusServer(status func(r *http.Request) int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status(r))
+		w.Write([]byte("{}"))
+	}))
+}
+
+func waitForEndpointHealth(t *testing.T, cluster sling.Cluster, index int, healthy bool) {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if cluster.Endpoints()[index].Healthy == healthy {
+			return
+		}
+	}
+	t.Fatalf("Expected endpoint %d to have health %v, but status was %+v", index, healthy, cluster.Endpoints()[index])
+}
+
+func TestHealth_UnhealthyEndpointsAreNotUsed(t *testing.T) {
+	healthy := newStatusServer(func(*http.Request) int { return http.StatusOK })
+	defer healthy.Close()
+	var requests int32
+	unhealthy := newStatusServer(func(r *http.Request) int {
+		if r.URL.Path == "/_up" {
+			return http.StatusServiceUnavailable
+		}
+		atomic.AddInt32(&requests, 1)
+		return http.StatusOK
+	})
+	defer unhealthy.Close()
+
+	cluster, err := sling.NewConnectionPool(sling.Config{}).HTTPCluster(
+		[]string{unhealthy.URL, healthy.URL},
+		sling.WithHealthCheck(sling.HealthCheck{Path: "/_up", Interval: 10 * time.Millisecond}),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error '%v' creating cluster", err)
+	}
+	defer cluster.Close()
+
+	waitForEndpointHealth(t, cluster, 0, false)
+	waitForEndpointHealth(t, cluster, 1, true)
+
+	for i := 0; i < 4; i++ {
+		if err := cluster.Do(sling.JSONRequest("GET", "/doc")); err != nil {
+			t.Fatalf("Unexpected error '%v' making request", err)
+		}
+	}
+
+	// Probes are counted among the requests of the endpoint, so only
+	// those received by the server besides them are compared.
+	if requests := atomic.LoadInt32(&requests); requests != 0 {
+		t.Errorf("Expected no requests to be made to the unhealthy endpoint, but %d were made", requests)
+	}
+}
+
+func TestHealth_FailingEndpointsAreEjected(t *testing.T) {
+	failing := newStatusServer(func(*http.Request) int { return http.StatusInternalServerError })
+	defer failing.Close()
+	working := newStatusServer(func(*http.Request) int { return http.StatusOK })
+	defer working.Close()
+
+	cluster, err := sling.NewConnectionPool(sling.Config{}).HTTPCluster(
+		[]string{failing.URL, working.URL},
+		sling.WithOutlierDetection(sling.OutlierDetection{ConsecutiveFailures: 2, Backoff: time.Minute}),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error '%v' creating cluster", err)
+	}
+	defer cluster.Close()
+
+	for i := 0; i < 8; i++ {
+		cluster.Do(sling.JSONRequest("GET", "/doc"))
+	}
+
+	endpoint := cluster.Endpoints()[0]
+	if endpoint.Healthy || endpoint.EjectedUntil.IsZero() {
+		t.Errorf("Expected failing endpoint to have been ejected, but status was %+v", endpoint)
+	}
+
+	if endpoint.Requests != 2 {
+		t.Errorf("Expected %d requests to the failing endpoint before its ejection, but %d were made", 2, endpoint.Requests)
+	}
+}
+
+func TestHealth_AllEndpointsAreUsedWhenNoneAreHealthy(t *testing.T) {
+	failing := newStatusServer(func(*http.Request) int { return http.StatusInternalServerError })
+	defer failing.Close()
+
+	cluster, err := sling.NewConnectionPool(sling.Config{}).HTTPCluster(
+		[]string{failing.URL},
+		sling.WithOutlierDetection(sling.OutlierDetection{ConsecutiveFailures: 1, Backoff: time.Minute}),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error '%v' creating cluster", err)
+	}
+	defer cluster.Close()
+
+	for i := 0; i < 3; i++ {
+		cluster.Do(sling.JSONRequest("GET", "/doc"))
+	}
+
+	if requests := cluster.Endpoints()[0].Requests; requests != 3 {
+		t.Errorf("Expected all %d requests to be made to the only endpoint, but %d were made", 3, requests)
+	}
+}
+
+func TestHealth_EndpointsExceedingTheTotalTimeoutAreEjected(t *testing.T) {
+	slow := newStallingServer(200*time.Millisecond, 0)
+	defer slow.Close()
+	working := newStatusServer(func(*http.Request) int { return http.StatusOK })
+	defer working.Close()
+
+	cluster, err := sling.NewConnectionPool(sling.Config{
+		Timeouts: sling.Timeouts{Total: 20 * time.Millisecond},
+	}).HTTPCluster(
+		[]string{slow.URL, working.URL},
+		sling.WithOutlierDetection(sling.OutlierDetection{ConsecutiveFailures: 2, Backoff: time.Minute}),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error '%v' creating cluster", err)
+	}
+	defer cluster.Close()
+
+	for i := 0; i < 8; i++ {
+		cluster.Do(sling.JSONRequest("GET", "/doc"))
+	}
+
+	endpoint := cluster.Endpoints()[0]
+	if endpoint.Healthy || endpoint.EjectedUntil.IsZero() {
+		t.Errorf("Expected timing out endpoint to have been ejected, but status was %+v", endpoint)
+	}
+	if endpoint.Requests != 2 {
+		t.Errorf("Expected %d requests to the timing out endpoint before its ejection, but %d were made", 2, endpoint.Requests)
+	}
+}
+
+func TestHealth_RequestsFailingBeforeBeingSentAreNoFailures(t *testing.T) {
+	working := newStatusServer(func(*http.Request) int { return http.StatusOK })
+	defer working.Close()
+	dir, _ := ioutil.TempDir("", "sling")
+	defer os.RemoveAll(dir)
+
+	cluster, err := sling.NewConnectionPool(sling.Config{}).HTTPCluster(
+		[]string{working.URL},
+		sling.WithAuthenticator(sling.BearerTokenFrom(sling.FileCredentials("", "", filepath.Join(dir, "token")))),
+		sling.WithOutlierDetection(sling.OutlierDetection{ConsecutiveFailures: 1, Backoff: time.Minute}),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error '%v' creating cluster", err)
+	}
+	defer cluster.Close()
+
+	for i := 0; i < 3; i++ {
+		if err := cluster.Do(sling.JSONRequest("GET", "/doc")); err == nil {
+			t.Fatal("Expected requests without credentials to fail")
+		}
+	}
+
+	if endpoint := cluster.Endpoints()[0]; !endpoint.Healthy || endpoint.ConsecutiveFailures != 0 {
+		t.Errorf("Expected the endpoint to remain healthy, but status was %+v", endpoint)
+	}
+}
+
+func TestHealth_ProbesAreAuthenticatedAndSigned(t *testing.T) {
+	probes := new(int32)
+	server := newStatusServer(func(r *http.Request) int {
+		if r.URL.Path != "/_up" {
+			return http.StatusOK
+		}
+		atomic.AddInt32(probes, 1)
+		if r.Header.Get("Authorization") != "Bearer token" || r.Header.Get("X-Probe") != "yes" || r.Header.Get("Signature") == "" {
+			return http.StatusUnauthorized
+		}
+		return http.StatusOK
+	})
+	defer server.Close()
+
+	cluster, err := sling.NewConnectionPool(sling.Config{}).HTTPCluster(
+		[]string{server.URL},
+		sling.WithAuthenticator(sling.BearerToken("token")),
+		sling.WithSigner(sling.HMACSigner("key", []byte("secret"))),
+		sling.WithHealthCheck(sling.HealthCheck{
+			Path:     "/_up",
+			Header:   http.Header{"X-Probe": {"yes"}},
+			Interval: 10 * time.Millisecond,
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error '%v' creating cluster", err)
+	}
+	defer cluster.Close()
+
+	for deadline := time.Now().Add(5 * time.Second); atomic.LoadInt32(probes) < 2 && time.Now().Before(deadline); {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if endpoint := cluster.Endpoints()[0]; !endpoint.Healthy {
+		t.Errorf("Expected the endpoint to accept the probe's credentials, but status was %+v", endpoint)
+	}
+}
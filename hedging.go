@@ -0,0 +1,227 @@
+package sling
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultHedgeDelay is the default time after which a request is hedged.
+const DefaultHedgeDelay = 100 * time.Millisecond
+
+// hedgeSamples is the number of recent latencies from which the hedge delay
+// is computed, and hedgeMinSamples the number required to do so.
+const (
+	hedgeSamples    = 100
+	hedgeMinSamples = 10
+)
+
+// maxHedgeTokens limits the number of hedges which may be sent in a burst.
+const maxHedgeTokens = 10
+
+// Hedging configures the sending of a second attempt of GET, HEAD and
+// OPTIONS requests without a body which have not been answered in time.
+//
+// The first response to arrive is used and the other attempt cancelled.
+// Hedges are subject to the pool's limits like any other request, and
+// clusters send them to the endpoint chosen by their Balancer, which may
+// be the same endpoint.
+type Hedging struct {
+	// Delay is the time after which a request is hedged, defaults to
+	// DefaultHedgeDelay if less than or equal to 0. It is only used until
+	// enough requests have completed if Percentile is set.
+	Delay time.Duration
+
+	// Percentile sets the delay to the given percentile, between 0 and 1,
+	// of the latencies of recent requests. A fixed delay is used if it is
+	// less than or equal to 0.
+	Percentile float64
+
+	// Budget is the maximum ratio of hedges to requests, defaults to 0.1
+	// if less than or equal to 0.
+	Budget float64
+}
+
+// WithHedging enables hedged requests for a HTTP client or Cluster.
+func WithHedging(hedging Hedging) HTTPOption {
+	if hedging.Delay <= 0 {
+		hedging.Delay = DefaultHedgeDelay
+	}
+	if hedging.Percentile > 1 {
+		hedging.Percentile = 1
+	}
+	if hedging.Budget <= 0 {
+		hedging.Budget = 0.1
+	}
+
+	return func(options *httpOptions) {
+		options.hedging = &hedging
+	}
+}
+
+// hedgingHTTPClient sends a second attempt of requests which are not
+// answered within the hedge delay, as long as its budget allows it.
+type hedgingHTTPClient struct {
+	netHTTPClient
+	Hedging
+
+	sync.Mutex
+	tokens    float64
+	latencies []time.Duration
+	next      int
+}
+
+func newHedgingHTTPClient(client netHTTPClient, hedging Hedging) *hedgingHTTPClient {
+	return &hedgingHTTPClient{
+		netHTTPClient: client,
+		Hedging:       hedging,
+		latencies:     make([]time.Duration, 0, hedgeSamples),
+	}
+}
+
+type hedgeResult struct {
+	response *http.Response
+	err      error
+	attempt  int
+	latency  time.Duration
+}
+
+func (client *hedgingHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	if !isRepeatable(req) {
+		return client.netHTTPClient.Do(req)
+	}
+	client.earn()
+
+	results := make(chan hedgeResult, 2)
+	cancels := make([]context.CancelFunc, 0, 2)
+	send := func() {
+		ctx, cancel := context.WithCancel(req.Context())
+		attempt := len(cancels)
+		cancels = append(cancels, cancel)
+		go func() {
+			start := time.Now()
+			response, err := client.netHTTPClient.Do(req.Clone(ctx))
+			results <- hedgeResult{response, err, attempt, time.Since(start)}
+		}()
+	}
+
+	send()
+	timer := time.NewTimer(client.delay())
+	defer timer.Stop()
+
+	var err error
+	for pending := 1; pending > 0; {
+		select {
+		case <-timer.C:
+			if client.spend() {
+				send()
+				pending++
+			}
+		case result := <-results:
+			pending--
+			if result.err != nil {
+				// The error of the original request is preferred, a
+				// hedge may have merely been shed.
+				if err == nil || result.attempt == 0 {
+					err = result.err
+				}
+				cancels[result.attempt]()
+				continue
+			}
+
+			client.record(result.latency)
+			for attempt, cancel := range cancels {
+				if attempt != result.attempt {
+					cancel()
+				}
+			}
+			go client.discardResponses(results, pending)
+			result.response.Body = &cancellingBody{result.response.Body, cancels[result.attempt]}
+			return result.response, nil
+		}
+	}
+	return nil, err
+}
+
+// isRepeatable returns whether req may safely be sent more than once.
+func isRepeatable(req *http.Request) bool {
+	switch req.Method {
+	case "GET", "HEAD", "OPTIONS":
+		return req.Body == nil || req.Body == http.NoBody
+	default:
+		return false
+	}
+}
+
+// earn adds the budget of a request to the hedges which may be sent.
+func (client *hedgingHTTPClient) earn() {
+	client.Lock()
+	defer client.Unlock()
+	client.tokens += client.Budget
+	if client.tokens > maxHedgeTokens {
+		client.tokens = maxHedgeTokens
+	}
+}
+
+// spend returns whether the budget allows a hedge to be sent, deducting
+// it if so.
+func (client *hedgingHTTPClient) spend() bool {
+	client.Lock()
+	defer client.Unlock()
+	if client.tokens < 1 {
+		return false
+	}
+	client.tokens--
+	return true
+}
+
+func (client *hedgingHTTPClient) record(latency time.Duration) {
+	client.Lock()
+	defer client.Unlock()
+	if len(client.latencies) < hedgeSamples {
+		client.latencies = append(client.latencies, latency)
+	} else {
+		client.latencies[client.next] = latency
+	}
+	client.next = (client.next + 1) % hedgeSamples
+}
+
+// delay returns the time after which a request is hedged.
+func (client *hedgingHTTPClient) delay() time.Duration {
+	client.Lock()
+	defer client.Unlock()
+	if client.Percentile <= 0 || len(client.latencies) < hedgeMinSamples {
+		return client.Delay
+	}
+
+	latencies := append([]time.Duration(nil), client.latencies...)
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	return latencies[int(client.Percentile*float64(len(latencies)-1))]
+}
+
+// discardResponses closes the responses of the remaining attempts, which
+// have been cancelled, recording the latency of those which had already
+// been answered.
+func (client *hedgingHTTPClient) discardResponses(results <-chan hedgeResult, pending int) {
+	for ; pending > 0; pending-- {
+		if result := <-results; result.err == nil {
+			client.record(result.latency)
+			result.response.Body.Close()
+		}
+	}
+}
+
+// cancellingBody cancels the context of its request once closed.
+type cancellingBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (body *cancellingBody) Close() error {
+	err := body.ReadCloser.Close()
+	body.cancel()
+	return err
+}
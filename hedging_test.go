@@ -0,0 +1,120 @@
+package sling
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newHedgedServer starts a server which answers its first request only once
+// it is cancelled, signalling the cancellation on cancelled, and all others
+// immediately.
+func newHedgedServer() (server *httptest.Server, requests *int32, cancelled chan struct{}) {
+	requests, cancelled = new(int32), make(chan struct{}, 1)
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Cancellation is only noticed once the body has been read.
+		ioutil.ReadAll(r.Body)
+		if atomic.AddInt32(requests, 1) == 1 {
+			<-r.Context().Done()
+			cancelled <- struct{}{}
+			return
+		}
+		w.Write([]byte("{}"))
+	}))
+	return server, requests, cancelled
+}
+
+func TestHedging_SlowRequestsAreHedged(t *testing.T) {
+	server, requests, cancelled := newHedgedServer()
+	defer server.Close()
+
+	pool := NewConnectionPool(Config{})
+	client, _ := pool.HTTP(server.URL, WithHedging(Hedging{Delay: 10 * time.Millisecond, Budget: 1}))
+
+	if err := client.Do(JSONRequest("GET", "")); err != nil {
+		t.Fatalf("Unexpected error for hedged request: %v", err)
+	}
+	if count := atomic.LoadInt32(requests); count != 2 {
+		t.Errorf("Expected %d requests to have been made, but %d were made", 2, count)
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Error("Expected the slower attempt to have been cancelled")
+	}
+}
+
+func TestHedging_HedgesAreLimitedByTheBudget(t *testing.T) {
+	server, requests, _ := newHedgedServer()
+	defer server.Close()
+
+	pool := NewConnectionPool(Config{})
+	client, _ := pool.HTTP(server.URL, WithHedging(Hedging{Delay: 10 * time.Millisecond, Budget: 0.5}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := client.Do(JSONRequest("GET", "").Context(ctx)); err == nil {
+		t.Error("Expected request without a hedge to time out")
+	}
+	if count := atomic.LoadInt32(requests); count != 1 {
+		t.Errorf("Expected %d request to have been made, but %d were made", 1, count)
+	}
+}
+
+func TestHedging_RequestsWithSideEffectsAreNotHedged(t *testing.T) {
+	server, requests, _ := newHedgedServer()
+	defer server.Close()
+
+	pool := NewConnectionPool(Config{})
+	client, _ := pool.HTTP(server.URL, WithHedging(Hedging{Delay: 10 * time.Millisecond, Budget: 1}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := client.Do(JSONRequest("POST", "").Body(map[string]string{}).Context(ctx)); err == nil {
+		t.Error("Expected unhedged request to time out")
+	}
+	if count := atomic.LoadInt32(requests); count != 1 {
+		t.Errorf("Expected %d request to have been made, but %d were made", 1, count)
+	}
+}
+
+func TestHedging_DelayIsAPercentileOfRecentLatencies(t *testing.T) {
+	client := newHedgingHTTPClient(nil, Hedging{Delay: time.Second, Percentile: 0.9})
+	for latency := 1; latency <= hedgeMinSamples-1; latency++ {
+		client.record(time.Duration(latency) * time.Millisecond)
+	}
+	if delay := client.delay(); delay != time.Second {
+		t.Errorf("Expected the fixed delay of %v before enough samples, but got %v", time.Second, delay)
+	}
+
+	for latency := hedgeMinSamples; latency <= 2*hedgeSamples; latency++ {
+		client.record(time.Duration(latency) * time.Millisecond)
+	}
+	if delay := client.delay(); delay != 190*time.Millisecond {
+		t.Errorf("Expected delay to be %v, but got %v", 190*time.Millisecond, delay)
+	}
+}
+
+func TestHedging_LatenciesAreMeasuredFromTheStartOfEachAttempt(t *testing.T) {
+	server, _, _ := newHedgedServer()
+	defer server.Close()
+
+	client := newHedgingHTTPClient(http.DefaultClient, Hedging{Delay: 20 * time.Millisecond, Budget: 1})
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	response, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Unexpected error for hedged request: %v", err)
+	}
+	response.Body.Close()
+
+	client.Lock()
+	defer client.Unlock()
+	if len(client.latencies) != 1 || client.latencies[0] >= 20*time.Millisecond {
+		t.Errorf("Expected the latency of the hedge alone to be recorded, but got %v", client.latencies)
+	}
+}
@@ -0,0 +1,56 @@
+package sling
+
+// HostStats contains the state of the limit of a host.
+type HostStats struct {
+	// Limit is the current concurrency limit of the host.
+	Limit int
+
+	// InFlight is the number of requests to the host which are currently
+	// holding a slot.
+	InFlight int
+
+	// Waiting is the number of requests to the host waiting for a slot.
+	Waiting int
+}
+
+// host returns the limited client for host, creating it if required. The
+// caller must hold the endpointsMutex, and release the host once it is no
+// longer used.
+func (pool *pool) host(host string) *throttledHTTPClient {
+	if pool.hosts[host] == nil {
+		pool.hosts[host] = pool.throttled(pool.upstream, pool.hostPoolSize(host))
+		if pool.throttledClient != nil {
+			pool.hosts[host].global = pool.throttledClient.semaphore
+		}
+	}
+	pool.hostRefs[host]++
+	return pool.hosts[host]
+}
+
+// releaseHost releases a reference to host, forgetting its limited client
+// once it is no longer used. The caller must hold the endpointsMutex.
+func (pool *pool) releaseHost(host string) {
+	if pool.hostRefs[host]--; pool.hostRefs[host] <= 0 {
+		delete(pool.hostRefs, host)
+		delete(pool.hosts, host)
+	}
+}
+
+// hostPoolSize returns the concurrency limit of host.
+func (pool *pool) hostPoolSize(host string) int {
+	if size := pool.HostPoolSizes[host]; size > 0 {
+		return size
+	}
+	return pool.poolSize
+}
+
+func (pool *pool) hostStats() map[string]HostStats {
+	pool.endpointsMutex.Lock()
+	defer pool.endpointsMutex.Unlock()
+
+	stats := make(map[string]HostStats, len(pool.hosts))
+	for host, throttledClient := range pool.hosts {
+		stats[host] = throttledClient.stats()
+	}
+	return stats
+}
@@ -1,11 +1,14 @@
 package sling
 
 import (
+	"context"
 	"errors"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 )
 
 // HTTPRequestable implementations create a request to the given base url
@@ -36,50 +39,139 @@ type HTTP interface {
 	Do(HTTPRequestable) error
 }
 
+// ErrResponseTooLarge is returned when a response body exceeds the maximum
+// response size while being processed.
+var ErrResponseTooLarge = errors.New("Response body exceeds the maximum response size")
+
 type httpClient struct {
 	netHTTPClient
 	*url.URL
+	maxDrainSize, maxResponseSize int64
+	lifecycle                     *lifecycle
+	totalTimeout                  time.Duration
+
+	// multiplexed is set if all requests use HTTP/2, making keep-alive
+	// headers pointless.
+	multiplexed bool
 }
 
 func newHTTP(baseURL string, client netHTTPClient) (HTTP, error) {
-	parsed, err := url.Parse(strings.TrimRight(baseURL, "/") + "/")
+	parsed, err := parseBaseURL(baseURL)
 	if err != nil {
 		return nil, err
 	}
 
-	if parsed.Scheme != "http" && parsed.Scheme != "https" {
-		return nil, errors.New("Only http and https are supported")
-	}
-
 	return &httpClient{
 		netHTTPClient: client,
 		URL:           parsed,
+		maxDrainSize:  DefaultMaxDrainSize,
 	}, nil
 }
 
+// parseBaseURL parses and validates baseURL, ensuring that it ends
+// with a slash.
+func parseBaseURL(baseURL string) (*url.URL, error) {
+	parsed, err := url.Parse(strings.TrimRight(baseURL, "/") + "/")
+	if err != nil {
+		return nil, err
+	}
+
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return nil, errors.New("Only http and https are supported")
+	}
+	return parsed, nil
+}
+
 func (client *httpClient) Do(requestable HTTPRequestable) error {
+	if client.lifecycle != nil {
+		if err := client.lifecycle.acquire(); err != nil {
+			return err
+		}
+		defer client.lifecycle.release()
+	}
+
 	request, responder, err := requestable.HTTPRequest(client.URL)
 	if err != nil {
 		return err
 	}
-	request.Header.Set("Connection", "keep-alive")
+	if !client.multiplexed {
+		request.Header.Set("Connection", "keep-alive")
+	}
+
+	overrides := requestOptionsFrom(request).timeouts
+	if timeout := (Timeouts{Total: client.totalTimeout}).override(overrides).Total; timeout > 0 {
+		ctx, cancel := context.WithTimeoutCause(request.Context(), timeout, &ErrTimeout{Phase: PhaseTotal, Duration: timeout})
+		defer cancel()
+		request = request.WithContext(ctx)
+	}
 
 	response, err := client.netHTTPClient.Do(request)
-	defer closeResponse(response)
+	defer closeResponse(response, client.maxDrainSize)
 	if err != nil {
-		return err
+		return timeoutFrom(request.Context(), err)
+	}
+	if client.maxResponseSize > 0 {
+		response.Body = &limitedBody{response.Body, client.maxResponseSize}
 	}
-	return responder.OnHTTPResponse(response)
+	return timeoutFrom(request.Context(), responder.OnHTTPResponse(response))
 }
 
-func closeResponse(response *http.Response) {
+func closeResponse(response *http.Response, maxDrainSize int64) {
 	if response != nil && response.Body != nil {
+		// Closing a HTTP/2 response body only resets its stream, so the
+		// connection remains usable without draining.
+		if response.ProtoMajor >= 2 {
+			response.Body.Close()
+			return
+		}
+
 		// NOTE(lcooper): we need to ensure that the response body sees an EOF,
 		// otherwise our connection will get closed down. But the JSON decoder
 		// stops reading once the outer object finishes, and CouchDB ends its
 		// responses with "\n", so we need to make sure that this is read.
-		// TODO(lcooper): test this, it's sort of important.
-		ioutil.ReadAll(response.Body)
+		drainBody(response.Body, maxDrainSize)
 		response.Body.Close()
 	}
 }
+
+// drainBody reads and discards at most maxDrainSize bytes of body, any
+// remainder is left unread so that closing body closes the connection
+// rather than reading an unbounded amount of data.
+func drainBody(body io.Reader, maxDrainSize int64) {
+	io.CopyN(ioutil.Discard, body, maxDrainSize+1)
+}
+
+// limitedBody fails with ErrResponseTooLarge once more than remaining
+// bytes have been read.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (body *limitedBody) Read(p []byte) (int, error) {
+	if body.remaining < 0 {
+		return 0, ErrResponseTooLarge
+	}
+	if int64(len(p)) > body.remaining+1 {
+		p = p[:body.remaining+1]
+	}
+	n, err := body.ReadCloser.Read(p)
+	body.remaining -= int64(n)
+	if body.remaining < 0 {
+		return n + int(body.remaining), ErrResponseTooLarge
+	}
+	return n, err
+}
+
+// pipeBody returns a reader yielding everything written by write, which
+// is run on its own goroutine blocking until the returned reader is read.
+//
+// Any error returned by write is passed on to the reader, and closing
+// the reader causes further writes to fail.
+func pipeBody(write func(io.Writer) error) io.ReadCloser {
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(write(writer))
+	}()
+	return reader
+}
@@ -0,0 +1,69 @@
+package sling
+
+import (
+	"net/http"
+	"time"
+)
+
+// HTTP2Mode selects when a ConnectionPool uses HTTP/2.
+type HTTP2Mode int
+
+const (
+	// HTTP2Disabled uses HTTP/1.1 for all requests.
+	HTTP2Disabled HTTP2Mode = iota
+
+	// HTTP2Negotiate uses HTTP/2 if the server selects it during the TLS
+	// handshake, and HTTP/1.1 otherwise or for plain http.
+	HTTP2Negotiate
+
+	// HTTP2Only uses HTTP/2 for all requests, negotiated during the TLS
+	// handshake or with prior knowledge (h2c) for plain http and Unix
+	// sockets. Servers not supporting HTTP/2 fail the request.
+	HTTP2Only
+)
+
+// HTTP2 configures the use of HTTP/2 by a ConnectionPool.
+//
+// As HTTP/2 multiplexes requests as streams over a single connection, the
+// PoolSize of a pool using it limits the number of concurrent streams to a
+// server rather than the number of connections. Requests exceeding the
+// number of concurrent streams permitted by a server open an additional
+// connection to it.
+type HTTP2 struct {
+	// Mode selects when HTTP/2 is used, defaults to HTTP2Disabled.
+	Mode HTTP2Mode
+
+	// PingInterval is the time after which a connection which received no
+	// frames is checked by sending a ping, no pings are sent if 0.
+	PingInterval time.Duration
+
+	// PingTimeout is the time after which a connection whose ping was not
+	// answered is closed, defaults to 15 seconds if 0.
+	PingTimeout time.Duration
+}
+
+// multiplexed reports whether all requests use HTTP/2.
+func (config HTTP2) multiplexed() bool {
+	return config.Mode == HTTP2Only
+}
+
+// configure applies config to transport.
+func (config HTTP2) configure(transport *http.Transport) {
+	protocols := new(http.Protocols)
+	switch config.Mode {
+	case HTTP2Negotiate:
+		protocols.SetHTTP1(true)
+		protocols.SetHTTP2(true)
+	case HTTP2Only:
+		protocols.SetHTTP2(true)
+		protocols.SetUnencryptedHTTP2(true)
+	default:
+		protocols.SetHTTP1(true)
+	}
+	transport.Protocols = protocols
+
+	transport.HTTP2 = &http.HTTP2Config{
+		SendPingTimeout: config.PingInterval,
+		PingTimeout:     config.PingTimeout,
+	}
+}
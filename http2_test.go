@@ -0,0 +1,154 @@
+package sling_test
+
+import (
+	"golang.struktur.de/sling"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newProtocolServer starts a server which records the protocol version and
+// Connection header of each request. It supports HTTP/2 over TLS if tls is
+// set and with prior knowledge otherwise.
+func newProtocolServer(tls bool, handler http.HandlerFunc) (server *httptest.Server, protoMajor *int32, connection *atomic.Value) {
+	protoMajor, connection = new(int32), new(atomic.Value)
+	server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.StoreInt32(protoMajor, int32(r.ProtoMajor))
+		connection.Store(r.Header.Get("Connection"))
+		if handler != nil {
+			handler(w, r)
+		}
+		w.Write([]byte("{}"))
+	}))
+	if tls {
+		server.EnableHTTP2 = true
+		server.StartTLS()
+	} else {
+		server.Config.Protocols = new(http.Protocols)
+		server.Config.Protocols.SetHTTP1(true)
+		server.Config.Protocols.SetUnencryptedHTTP2(true)
+		server.Start()
+	}
+	return server, protoMajor, connection
+}
+
+func TestHTTP2_IsDisabledByDefault(t *testing.T) {
+	server, protoMajor, connection := newProtocolServer(true, nil)
+	defer server.Close()
+
+	client, _ := sling.NewConnectionPool(sling.Config{SkipSSLValidation: true}).HTTP(server.URL)
+	if err := client.Do(sling.JSONRequest("GET", "")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if major := atomic.LoadInt32(protoMajor); major != 1 {
+		t.Errorf("Expected HTTP/%d to be used, but got HTTP/%d", 1, major)
+	}
+	if header := connection.Load(); header != "keep-alive" {
+		t.Errorf("Expected Connection header %q, but got %q", "keep-alive", header)
+	}
+}
+
+func TestHTTP2_IsNegotiatedOverTLS(t *testing.T) {
+	server, protoMajor, _ := newProtocolServer(true, nil)
+	defer server.Close()
+
+	client, _ := sling.NewConnectionPool(sling.Config{
+		SkipSSLValidation: true,
+		HTTP2:             sling.HTTP2{Mode: sling.HTTP2Negotiate},
+	}).HTTP(server.URL)
+	if err := client.Do(sling.JSONRequest("GET", "")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if major := atomic.LoadInt32(protoMajor); major != 2 {
+		t.Errorf("Expected HTTP/%d to be used, but got HTTP/%d", 2, major)
+	}
+}
+
+func TestHTTP2_NegotiationFallsBackToHTTP1ForPlainHTTP(t *testing.T) {
+	server, protoMajor, _ := newProtocolServer(false, nil)
+	defer server.Close()
+
+	client, _ := sling.NewConnectionPool(sling.Config{
+		HTTP2: sling.HTTP2{Mode: sling.HTTP2Negotiate},
+	}).HTTP(server.URL)
+	if err := client.Do(sling.JSONRequest("GET", "")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if major := atomic.LoadInt32(protoMajor); major != 1 {
+		t.Errorf("Expected HTTP/%d to be used, but got HTTP/%d", 1, major)
+	}
+}
+
+func TestHTTP2_PriorKnowledgeIsUsedForPlainHTTP(t *testing.T) {
+	server, protoMajor, connection := newProtocolServer(false, nil)
+	defer server.Close()
+
+	client, _ := sling.NewConnectionPool(sling.Config{
+		HTTP2: sling.HTTP2{Mode: sling.HTTP2Only},
+	}).HTTP(server.URL)
+	if err := client.Do(sling.JSONRequest("GET", "")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if major := atomic.LoadInt32(protoMajor); major != 2 {
+		t.Errorf("Expected HTTP/%d to be used, but got HTTP/%d", 2, major)
+	}
+	if header := connection.Load(); header != "" {
+		t.Errorf("Expected no Connection header, but got %q", header)
+	}
+}
+
+func TestHTTP2_PoolSizeLimitsConcurrentStreams(t *testing.T) {
+	var inFlight, maxInFlight int32
+	var connections sync.Map
+	server, _, _ := newProtocolServer(false, func(w http.ResponseWriter, r *http.Request) {
+		connections.Store(r.RemoteAddr, true)
+		current := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if current <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, current) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	})
+	defer server.Close()
+
+	client, _ := sling.NewConnectionPool(sling.Config{
+		PoolSize: 2,
+		HTTP2:    sling.HTTP2{Mode: sling.HTTP2Only},
+	}).HTTP(server.URL)
+
+	// The first request establishes the connection, which all further
+	// requests should then share.
+	if err := client.Do(sling.JSONRequest("GET", "")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := client.Do(sling.JSONRequest("GET", "")); err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if max := atomic.LoadInt32(&maxInFlight); max != 2 {
+		t.Errorf("Expected at most %d concurrent streams, but got %d", 2, max)
+	}
+	count := 0
+	connections.Range(func(_, _ interface{}) bool {
+		count++
+		return true
+	})
+	if count != 1 {
+		t.Errorf("Expected streams to share %d connection, but %d were opened", 1, count)
+	}
+}
@@ -1,7 +1,14 @@
 package sling
 
 import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestHTTP_newFailsForMalformedURLs(t *testing.T) {
@@ -28,3 +35,107 @@ func TestHTTP_newEnsuresBaseURLHasATrailingSlash(t *testing.T) {
 		t.Errorf("Expected processed url to be %s, but was %s", expectedURL, actualURL)
 	}
 }
+
+// newConnectionCountingServer starts a server responding to all requests
+// with the given status and body, and which counts the connections made
+// to it.
+func newConnectionCountingServer(status int, body string) (*httptest.Server, *int32) {
+	connections := new(int32)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(connections, 1)
+		}
+	}
+	server.Start()
+	return server, connections
+}
+
+func doRequests(client HTTP, count int, requestable func() HTTPRequestable) {
+	for i := 0; i < count; i++ {
+		client.Do(requestable())
+	}
+}
+
+func TestHTTP_connectionIsReusedAfterTrailingNewline(t *testing.T) {
+	// CouchDB ends its responses with "\n", which the JSON decoder
+	// doesn't read.
+	server, connections := newConnectionCountingServer(http.StatusOK, `{"ok": true}`+"\n")
+	defer server.Close()
+
+	client, _ := NewHTTP(server.URL, Config{PoolSize: 1})
+	doRequests(client, 3, func() HTTPRequestable {
+		return JSONRequest("GET", "").Success(&struct{}{})
+	})
+
+	if count := atomic.LoadInt32(connections); count != 1 {
+		t.Errorf("Expected a single connection to be reused, but %d were opened", count)
+	}
+}
+
+func TestHTTP_connectionIsReusedAfterDrainingUnprocessedBody(t *testing.T) {
+	server, connections := newConnectionCountingServer(http.StatusInternalServerError, strings.Repeat("x", 1024))
+	defer server.Close()
+
+	client, _ := NewHTTP(server.URL, Config{PoolSize: 1})
+	doRequests(client, 3, func() HTTPRequestable {
+		return JSONRequest("GET", "")
+	})
+
+	if count := atomic.LoadInt32(connections); count != 1 {
+		t.Errorf("Expected a single connection to be reused, but %d were opened", count)
+	}
+}
+
+func TestHTTP_connectionIsClosedWhenBodyExceedsMaxDrainSize(t *testing.T) {
+	// Newer versions of net/http drain small bodies themselves, so
+	// the body must exceed their limit too.
+	server, connections := newConnectionCountingServer(http.StatusInternalServerError, strings.Repeat("x", 1024*1024))
+	defer server.Close()
+
+	client, _ := NewHTTP(server.URL, Config{PoolSize: 1})
+	doRequests(client, 3, func() HTTPRequestable {
+		return JSONRequest("GET", "")
+	})
+
+	if count := atomic.LoadInt32(connections); count != 3 {
+		t.Errorf("Expected a connection per request, but %d were opened", count)
+	}
+}
+
+func TestHTTP_responsesLargerThanMaxResponseSizeFail(t *testing.T) {
+	server, _ := newConnectionCountingServer(http.StatusOK, `{"field": "`+strings.Repeat("x", 1024)+`"}`)
+	defer server.Close()
+
+	client, _ := NewHTTP(server.URL, Config{MaxResponseSize: 512})
+	if err := client.Do(JSONRequest("GET", "").Success(&struct{}{})); err != ErrResponseTooLarge {
+		t.Errorf("Expected error to be '%v', but was '%v'", ErrResponseTooLarge, err)
+	}
+
+	client, _ = NewHTTP(server.URL, Config{MaxResponseSize: 2048})
+	if err := client.Do(JSONRequest("GET", "").Success(&struct{}{})); err != nil {
+		t.Errorf("Unexpected error '%v' for response within the maximum size", err)
+	}
+}
+
+func TestHTTPCluster_ClosedClustersAreForgotten(t *testing.T) {
+	pool := NewConnectionPool(Config{
+		Resolver: StaticResolver(map[string][]string{"payments": {"http://127.0.0.1:1"}}),
+	}).(*pool)
+	defer pool.Shutdown(context.Background())
+
+	for i := 0; i < 3; i++ {
+		cluster, err := pool.HTTPCluster([]string{"service://payments"}, WithHealthCheck(HealthCheck{Interval: time.Minute}))
+		if err != nil {
+			t.Fatalf("Unexpected error creating cluster: %v", err)
+		}
+		cluster.Close()
+	}
+
+	if len(pool.clusters) != 0 {
+		t.Errorf("Expected closed clusters to be forgotten, but %d were kept", len(pool.clusters))
+	}
+}
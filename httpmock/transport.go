@@ -5,6 +5,9 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
 	"net/http"
 	"strings"
 	"testing"
@@ -165,6 +168,28 @@ func (fake *Transport) AssertRequestAccepts(contentType string) {
 	}
 }
 
+// AssertRequestContentLength tests that the request has the given content length.
+func (fake *Transport) AssertRequestContentLength(contentLength int64) {
+	fake.assertRequestMade()
+
+	if actual := fake.request.ContentLength; actual != contentLength {
+		fake.t.Errorf("Expected HTTP request to have content length %d, but was %d", contentLength, actual)
+	}
+}
+
+// AssertRequestBody tests that the request body is equal to body.
+func (fake *Transport) AssertRequestBody(body string) {
+	fake.assertRequestMade()
+
+	if fake.request.Body == nil {
+		fake.t.Error("HTTP request was made without a body")
+	} else if actual, err := ioutil.ReadAll(fake.request.Body); err != nil {
+		fake.t.Errorf("Failed to read HTTP request body: %v", err)
+	} else if string(actual) != body {
+		fake.t.Errorf("Expected HTTP request to have body '%s', but was '%s'", body, actual)
+	}
+}
+
 // AssertRequestBodyJSON tests that a request body was provided, and if so, creates a JSON
 // decoder using it, and passes it to cb for further tests.
 //
@@ -178,6 +203,22 @@ func (fake *Transport) AssertRequestBodyJSON(cb func(*json.Decoder)) {
 	}
 }
 
+// AssertRequestBodyMultipart tests that a multipart request body was provided,
+// and if so, creates a multipart reader using it, and passes it to cb for
+// further tests.
+func (fake *Transport) AssertRequestBodyMultipart(cb func(*multipart.Reader)) {
+	fake.assertRequestMade()
+
+	mediaType, params, err := mime.ParseMediaType(fake.request.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		fake.t.Errorf("Expected HTTP request to have a multipart content type, but was '%s'", fake.request.Header.Get("Content-Type"))
+	} else if fake.request.Body == nil {
+		fake.t.Error("HTTP request was made without a body")
+	} else {
+		cb(multipart.NewReader(fake.request.Body, params["boundary"]))
+	}
+}
+
 // AssertResponseBodyClosed tests that the function under test closed the response body reader.
 func (fake *Transport) AssertResponseBodyClosed() {
 	if src, ok := fake.response.Body.(*stringReaderCloser); ok {
@@ -2,8 +2,10 @@ package sling
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"strings"
@@ -32,6 +34,50 @@ type JSONRequestBuilder interface {
 	// to create the body of the HTTP request.
 	Body(JSON) JSONRequestBuilder
 
+	// Stream causes the body set by Body to be encoded directly into the
+	// HTTP request as it is sent, rather than being buffered in memory
+	// beforehand. Such requests are sent using chunked transfer encoding.
+	//
+	// Note that encoding errors will then be reported as a failure of
+	// the request itself.
+	Stream() JSONRequestBuilder
+
+	// RawBody sets a reader from which the body of the HTTP request will
+	// be streamed as is, with the given content type. It takes precedence
+	// over any body set by Body.
+	//
+	// If length is negative, the length of the body is unknown and it will
+	// be sent using chunked transfer encoding.
+	//
+	// The request takes ownership of body, which is closed once the
+	// request was sent or failed if it implements io.Closer.
+	RawBody(contentType string, body io.Reader, length int64) JSONRequestBuilder
+
+	// Compress sets the content encoding used to compress the request body,
+	// overriding the pool's RequestCompression. IdentityEncoding may be
+	// used to disable compression.
+	Compress(encoding string) JSONRequestBuilder
+
+	// Trace enables the collection of connection diagnostics for the
+	// request, which are stored in result once a response was received.
+	Trace(result *TraceResult) JSONRequestBuilder
+
+	// Key sets the key by which a Cluster's Balancer may choose the
+	// endpoint of the request, see Balancer.
+	Key(key string) JSONRequestBuilder
+
+	// Context sets the context of the HTTP request, which defaults to
+	// context.Background().
+	Context(ctx context.Context) JSONRequestBuilder
+
+	// Priority sets the priority of the request when waiting for a slot
+	// of the pool, overriding any priority set on its context.
+	Priority(priority Priority) JSONRequestBuilder
+
+	// Timeouts overrides the pool's timeouts for the request, fields which
+	// are 0 keep the pool's timeout while negative values disable it.
+	Timeouts(timeouts Timeouts) JSONRequestBuilder
+
 	// Response sets an optional object to which any response will be
 	// deserialized as JSON.
 	Response(JSON) JSONRequestBuilder
@@ -67,24 +113,49 @@ type JSONRequestBuilder interface {
 }
 
 type jsonRequest struct {
-	method, path           string
-	body, success, failure JSON
-	statusErrors           map[int]error
-	statusIsRPC            bool
-	headers                http.Header
+	ctx     context.Context
+	path    string
+	body    JSON
+	stream  bool
+	raw     *rawBody
+	headers http.Header
+	requestOptions
+	jsonResponder
+}
+
+type rawBody struct {
+	contentType string
+	io.Reader
+	length int64
+}
+
+// jsonResponder decodes JSON responses and maps their HTTP status to
+// errors, it is shared by all request builders producing JSON responses.
+type jsonResponder struct {
+	method           string
+	success, failure JSON
+	statusErrors     map[int]error
+	statusIsRPC      bool
 	*url.URL
 }
 
+func newJSONResponder(method string) jsonResponder {
+	return jsonResponder{
+		method:       method,
+		statusErrors: make(map[int]error),
+	}
+}
+
 // JSONRequest creates a new builder for a request with the
 // given HTTP method and path.
 //
 // Note that while neither are currently validated, this is subject to change.
 func JSONRequest(method, path string) JSONRequestBuilder {
 	return &jsonRequest{
-		method:       method,
-		path:         path,
-		statusErrors: make(map[int]error),
-		headers:      make(http.Header),
+		ctx:           context.Background(),
+		path:          path,
+		headers:       make(http.Header),
+		jsonResponder: newJSONResponder(method),
 	}
 }
 
@@ -98,6 +169,46 @@ func (request *jsonRequest) Body(body JSON) JSONRequestBuilder {
 	return request
 }
 
+func (request *jsonRequest) Stream() JSONRequestBuilder {
+	request.stream = true
+	return request
+}
+
+func (request *jsonRequest) RawBody(contentType string, body io.Reader, length int64) JSONRequestBuilder {
+	request.raw = &rawBody{contentType, body, length}
+	return request
+}
+
+func (request *jsonRequest) Compress(encoding string) JSONRequestBuilder {
+	request.compression = encoding
+	return request
+}
+
+func (request *jsonRequest) Trace(result *TraceResult) JSONRequestBuilder {
+	request.trace = result
+	return request
+}
+
+func (request *jsonRequest) Key(key string) JSONRequestBuilder {
+	request.key = key
+	return request
+}
+
+func (request *jsonRequest) Context(ctx context.Context) JSONRequestBuilder {
+	request.ctx = ctx
+	return request
+}
+
+func (request *jsonRequest) Priority(priority Priority) JSONRequestBuilder {
+	request.priority = &priority
+	return request
+}
+
+func (request *jsonRequest) Timeouts(timeouts Timeouts) JSONRequestBuilder {
+	request.timeouts = &timeouts
+	return request
+}
+
 func (request *jsonRequest) Response(body JSON) JSONRequestBuilder {
 	request.success = body
 	request.failure = body
@@ -128,17 +239,19 @@ func (request *jsonRequest) HTTPRequest(baseURL *url.URL) (*http.Request, HTTPRe
 	requestedURL, _ := url.Parse(strings.TrimLeft(request.path, "/"))
 	request.URL = baseURL.ResolveReference(requestedURL)
 
-	body := new(bytes.Buffer)
-	if request.body != nil {
-		if err := json.NewEncoder(body).Encode(request.body); err != nil {
-			return nil, nil, err
-		}
+	body, contentLength, err := request.requestBody()
+	if err != nil {
+		return nil, nil, err
 	}
 
-	req, err := http.NewRequest(request.method, request.URL.String(), body)
+	req, err := http.NewRequestWithContext(request.ctx, request.method, request.URL.String(), body)
 	if err != nil {
+		if closer, ok := body.(io.Closer); ok {
+			closer.Close()
+		}
 		return nil, nil, err
 	}
+	req.ContentLength = contentLength
 
 	for name, values := range request.headers {
 		for _, value := range values {
@@ -146,13 +259,56 @@ func (request *jsonRequest) HTTPRequest(baseURL *url.URL) (*http.Request, HTTPRe
 		}
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	contentType := "application/json"
+	if request.raw != nil {
+		contentType = request.raw.contentType
+	}
+	req.Header.Set("Content-Type", contentType)
 	req.Header.Set("Accept", "application/json")
 
-	return req, request, nil
+	return withRequestOptions(req, request.requestOptions), request, nil
+}
+
+// requestBody returns the body of the HTTP request as well as its length,
+// which is negative if unknown.
+func (request *jsonRequest) requestBody() (io.Reader, int64, error) {
+	if request.raw != nil {
+		closer, _ := request.raw.Reader.(io.Closer)
+		if request.raw.length == 0 {
+			if closer != nil {
+				closer.Close()
+			}
+			return http.NoBody, 0, nil
+		}
+		if request.raw.length < 0 {
+			return request.raw.Reader, -1, nil
+		}
+		limited := io.LimitReader(request.raw.Reader, request.raw.length)
+		if closer != nil {
+			return struct {
+				io.Reader
+				io.Closer
+			}{limited, closer}, request.raw.length, nil
+		}
+		return limited, request.raw.length, nil
+	}
+
+	if request.stream && request.body != nil {
+		return pipeBody(func(w io.Writer) error {
+			return json.NewEncoder(w).Encode(request.body)
+		}), -1, nil
+	}
+
+	body := new(bytes.Buffer)
+	if request.body != nil {
+		if err := json.NewEncoder(body).Encode(request.body); err != nil {
+			return nil, 0, err
+		}
+	}
+	return body, int64(body.Len()), nil
 }
 
-func (responder *jsonRequest) OnHTTPResponse(res *http.Response) error {
+func (responder *jsonResponder) OnHTTPResponse(res *http.Response) error {
 	decoder := json.NewDecoder(res.Body)
 	if res.StatusCode < http.StatusBadRequest {
 		if responder.success != nil {
@@ -166,7 +322,7 @@ func (responder *jsonRequest) OnHTTPResponse(res *http.Response) error {
 		}
 		return nil
 	} else {
-		err := fmt.Errorf("request %s %s as JSON returned status %d", responder.method, responder.URL, res.StatusCode)
+		err := fmt.Errorf("request %s %s as JSON returned status %d", responder.method, sentURL(res, responder.URL), res.StatusCode)
 
 		// TODO(lcooper): We should also decode the failure body if provided.
 		// Unclear what should be returned if there's both a StatusError
@@ -189,6 +345,19 @@ func (responder *jsonRequest) OnHTTPResponse(res *http.Response) error {
 	}
 }
 
+// sentURL returns the URL the request answered by res was first sent to,
+// which differs from requested if a cluster chose one of its endpoints.
+func sentURL(res *http.Response, requested *url.URL) *url.URL {
+	if res.Request == nil {
+		return requested
+	}
+	req := res.Request
+	for req.Response != nil && req.Response.Request != nil {
+		req = req.Response.Request
+	}
+	return req.URL
+}
+
 func asError(response interface{}, defaultError error) error {
 	switch v := response.(type) {
 	case Errorable:
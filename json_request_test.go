@@ -7,9 +7,12 @@ import (
 	"golang.struktur.de/sling"
 	"golang.struktur.de/sling/httpmock"
 	"golang.struktur.de/sling/slingmock"
+	nethttp "net/http"
+	"net/http/httptest"
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 )
 
 var requestURL, _ = url.Parse("http://example.com/doc/")
@@ -252,3 +255,86 @@ func TestJson_RequestReceivesRPCError(t *testing.T) {
 		t.Errorf("Expected error to be '%v', but was '%v'", errorableError, err)
 	}
 }
+
+func TestJson_RequestStreamsBodyWithChunkedEncoding(t *testing.T) {
+	doc := map[string]string{"field": "value"}
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if len(r.TransferEncoding) != 1 || r.TransferEncoding[0] != "chunked" {
+			t.Errorf("Expected request to use chunked transfer encoding, but was %v", r.TransferEncoding)
+		}
+
+		requestJSON := make(map[string]string)
+		if err := json.NewDecoder(r.Body).Decode(&requestJSON); err != nil {
+			t.Errorf("Failed to unmarshal request JSON: %v", err)
+		}
+		if requestJSON["field"] != doc["field"] {
+			t.Errorf("Request json %v did not contain document properties", requestJSON)
+		}
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	http, err := sling.NewHTTP(server.URL, sling.Config{})
+	if err != nil {
+		t.Fatalf("Unexpected error '%v' creating client", err)
+	}
+
+	if err := http.Do(sling.JSONRequest("POST", "").Body(doc).Stream()); err != nil {
+		t.Errorf("Unexpected error '%v' making request", err)
+	}
+}
+
+func TestJson_RequestSendsRawBody(t *testing.T) {
+	content := "raw,content"
+	http, transport := newTestHTTP(t)
+	transport.SetResponseStatusOK()
+	transport.SetResponseBodyValidJSON()
+
+	request := sling.JSONRequest("PUT", "").
+		Body(map[string]string{"ignored": "value"}).
+		RawBody("text/csv", strings.NewReader(content), int64(len(content)))
+	if err := http.Do(request); err != nil {
+		t.Fatalf("Unexpected error '%v' making request", err)
+	}
+
+	transport.AssertRequestContentType("text/csv")
+	transport.AssertRequestAccepts("application/json")
+	transport.AssertRequestContentLength(int64(len(content)))
+	transport.AssertRequestBody(content)
+}
+
+// closeRecorder is a request body signalling on closed once it was closed,
+// which the transport may do after a request has returned.
+type closeRecorder struct {
+	*strings.Reader
+	closed chan struct{}
+}
+
+func (recorder *closeRecorder) Close() error {
+	select {
+	case recorder.closed <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func TestJson_RawBodiesAreClosedWhateverTheirLength(t *testing.T) {
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+	client, _ := sling.NewConnectionPool(sling.Config{}).HTTP(server.URL)
+
+	content := "raw,content"
+	for _, length := range []int64{-1, 0, int64(len(content))} {
+		body := &closeRecorder{Reader: strings.NewReader(content), closed: make(chan struct{}, 1)}
+		if err := client.Do(sling.JSONRequest("PUT", "").RawBody("text/csv", body, length)); err != nil {
+			t.Fatalf("Unexpected error '%v' making request", err)
+		}
+		select {
+		case <-body.closed:
+		case <-time.After(time.Second):
+			t.Errorf("Expected the raw body of length %d to be closed", length)
+		}
+	}
+}
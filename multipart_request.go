@@ -0,0 +1,269 @@
+package sling
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+)
+
+// MultipartRequestBuilder instances allow the construction of a HTTP request
+// with a multipart/form-data body whose response is a JSON document.
+//
+// Parts are written in the order they were added, and their content is
+// streamed into the request as it is sent rather than being buffered
+// in memory. As the provided readers are consumed when the request is
+// made, a MultipartRequestBuilder may only be used for a single request.
+type MultipartRequestBuilder interface {
+	// Header sets an optional HTTP request header.
+	Header(name, value string) MultipartRequestBuilder
+
+	// Field adds a form field with the given name and value.
+	Field(name, value string) MultipartRequestBuilder
+
+	// JSONField adds a form field with the given name whose content
+	// is value serialized as JSON.
+	JSONField(name string, value JSON) MultipartRequestBuilder
+
+	// File adds a file part with the given form field name and filename,
+	// whose content is read from content.
+	//
+	// If contentType is empty, application/octet-stream is used.
+	File(name, filename, contentType string, content io.Reader) MultipartRequestBuilder
+
+	// Part adds a part with arbitrary MIME headers, whose content is
+	// read from content.
+	Part(header textproto.MIMEHeader, content io.Reader) MultipartRequestBuilder
+
+	// Compress sets the content encoding used to compress the request body,
+	// overriding the pool's RequestCompression. IdentityEncoding may be
+	// used to disable compression.
+	Compress(encoding string) MultipartRequestBuilder
+
+	// Trace enables the collection of connection diagnostics for the
+	// request, which are stored in result once a response was received.
+	Trace(result *TraceResult) MultipartRequestBuilder
+
+	// Key sets the key by which a Cluster's Balancer may choose the
+	// endpoint of the request, see Balancer.
+	Key(key string) MultipartRequestBuilder
+
+	// Context sets the context of the HTTP request, which defaults to
+	// context.Background().
+	Context(ctx context.Context) MultipartRequestBuilder
+
+	// Priority sets the priority of the request when waiting for a slot
+	// of the pool, overriding any priority set on its context.
+	Priority(priority Priority) MultipartRequestBuilder
+
+	// Timeouts overrides the pool's timeouts for the request, fields which
+	// are 0 keep the pool's timeout while negative values disable it.
+	Timeouts(timeouts Timeouts) MultipartRequestBuilder
+
+	// Response sets an optional object to which any response will be
+	// deserialized as JSON.
+	Response(JSON) MultipartRequestBuilder
+
+	// Success sets an optional object to which successful responses
+	// will be deserialized.
+	Success(JSON) MultipartRequestBuilder
+
+	// Failure sets an optional object to which unsuccessful responses
+	// will be deserialized, see JSONRequestBuilder for details.
+	Failure(JSON) MultipartRequestBuilder
+
+	// StatusError sets the error return for responses with HTTP status
+	// statusCode to err.
+	StatusError(statusCode int, err error) MultipartRequestBuilder
+
+	// StatusIsRPC indicates that the deserialized response should be
+	// interpreted as described by Failure in all cases.
+	StatusIsRPC() MultipartRequestBuilder
+
+	// HTTPRequestable methods may be used to initiate a request/response cycle.
+	HTTPRequestable
+}
+
+type multipartPart struct {
+	header  textproto.MIMEHeader
+	content io.Reader
+	value   JSON
+}
+
+func (part *multipartPart) writeTo(w io.Writer) error {
+	if part.content == nil {
+		return json.NewEncoder(w).Encode(part.value)
+	}
+	_, err := io.Copy(w, part.content)
+	return err
+}
+
+type multipartRequest struct {
+	ctx     context.Context
+	path    string
+	parts   []multipartPart
+	headers http.Header
+	requestOptions
+	jsonResponder
+}
+
+// MultipartRequest creates a new builder for a multipart/form-data request
+// with the given HTTP method and path.
+func MultipartRequest(method, path string) MultipartRequestBuilder {
+	return &multipartRequest{
+		ctx:           context.Background(),
+		path:          path,
+		headers:       make(http.Header),
+		jsonResponder: newJSONResponder(method),
+	}
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func formDataHeader(name, filename, contentType string) textproto.MIMEHeader {
+	header := make(textproto.MIMEHeader)
+	disposition := fmt.Sprintf(`form-data; name="%s"`, quoteEscaper.Replace(name))
+	if filename != "" {
+		disposition += fmt.Sprintf(`; filename="%s"`, quoteEscaper.Replace(filename))
+	}
+	header.Set("Content-Disposition", disposition)
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	return header
+}
+
+func (request *multipartRequest) Header(name, value string) MultipartRequestBuilder {
+	request.headers.Add(name, value)
+	return request
+}
+
+func (request *multipartRequest) Field(name, value string) MultipartRequestBuilder {
+	return request.Part(formDataHeader(name, "", ""), strings.NewReader(value))
+}
+
+func (request *multipartRequest) JSONField(name string, value JSON) MultipartRequestBuilder {
+	header := formDataHeader(name, "", "application/json")
+	request.parts = append(request.parts, multipartPart{header: header, value: value})
+	return request
+}
+
+func (request *multipartRequest) File(name, filename, contentType string, content io.Reader) MultipartRequestBuilder {
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return request.Part(formDataHeader(name, filename, contentType), content)
+}
+
+func (request *multipartRequest) Part(header textproto.MIMEHeader, content io.Reader) MultipartRequestBuilder {
+	request.parts = append(request.parts, multipartPart{header: header, content: content})
+	return request
+}
+
+func (request *multipartRequest) Compress(encoding string) MultipartRequestBuilder {
+	request.compression = encoding
+	return request
+}
+
+func (request *multipartRequest) Trace(result *TraceResult) MultipartRequestBuilder {
+	request.trace = result
+	return request
+}
+
+func (request *multipartRequest) Key(key string) MultipartRequestBuilder {
+	request.key = key
+	return request
+}
+
+func (request *multipartRequest) Context(ctx context.Context) MultipartRequestBuilder {
+	request.ctx = ctx
+	return request
+}
+
+func (request *multipartRequest) Priority(priority Priority) MultipartRequestBuilder {
+	request.priority = &priority
+	return request
+}
+
+func (request *multipartRequest) Timeouts(timeouts Timeouts) MultipartRequestBuilder {
+	request.timeouts = &timeouts
+	return request
+}
+
+func (request *multipartRequest) Response(body JSON) MultipartRequestBuilder {
+	request.success = body
+	request.failure = body
+	return request
+}
+
+func (request *multipartRequest) Success(body JSON) MultipartRequestBuilder {
+	request.success = body
+	return request
+}
+
+func (request *multipartRequest) Failure(body JSON) MultipartRequestBuilder {
+	request.failure = body
+	return request
+}
+
+func (request *multipartRequest) StatusError(statusCode int, err error) MultipartRequestBuilder {
+	request.statusErrors[statusCode] = err
+	return request
+}
+
+func (request *multipartRequest) StatusIsRPC() MultipartRequestBuilder {
+	request.statusIsRPC = true
+	return request
+}
+
+func (request *multipartRequest) HTTPRequest(baseURL *url.URL) (*http.Request, HTTPResponder, error) {
+	requestedURL, _ := url.Parse(strings.TrimLeft(request.path, "/"))
+	request.URL = baseURL.ResolveReference(requestedURL)
+
+	// The boundary is chosen up front, as it is part of the content
+	// type while the form is only written once sent.
+	boundary := multipart.NewWriter(nil).Boundary()
+	contentType := "multipart/form-data; boundary=" + boundary
+	body := pipeBody(func(w io.Writer) error {
+		form := multipart.NewWriter(w)
+		if err := form.SetBoundary(boundary); err != nil {
+			return err
+		}
+		return request.writeParts(form)
+	})
+
+	req, err := http.NewRequestWithContext(request.ctx, request.method, request.URL.String(), body)
+	if err != nil {
+		body.Close()
+		return nil, nil, err
+	}
+
+	for name, values := range request.headers {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", "application/json")
+
+	return withRequestOptions(req, request.requestOptions), request, nil
+}
+
+func (request *multipartRequest) writeParts(form *multipart.Writer) error {
+	for _, part := range request.parts {
+		partWriter, err := form.CreatePart(part.header)
+		if err != nil {
+			return err
+		}
+		if err := part.writeTo(partWriter); err != nil {
+			return err
+		}
+	}
+	return form.Close()
+}
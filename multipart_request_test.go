@@ -0,0 +1,105 @@
+package sling_test
+
+import (
+	"errors"
+	"golang.struktur.de/sling"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMultipart_RequestSuppliesAppropriateHeaders(t *testing.T) {
+	http, transport := newTestHTTP(t)
+	transport.SetResponseStatusOK()
+	transport.SetResponseBodyValidJSON()
+
+	if err := http.Do(sling.MultipartRequest("POST", "").Header("X-Foo-Status", "bar")); err != nil {
+		t.Fatalf("Unexpected error '%v' making request", err)
+	}
+
+	transport.AssertRequestMethod("POST")
+	transport.AssertRequestHeader("X-Foo-Status", "bar")
+	transport.AssertRequestAccepts("application/json")
+}
+
+func TestMultipart_RequestStreamsPartsInOrder(t *testing.T) {
+	http, transport := newTestHTTP(t)
+	transport.SetResponseStatusOK()
+	transport.SetResponseBodyValidJSON()
+
+	request := sling.MultipartRequest("POST", "/attachments").
+		Field("name", "value").
+		JSONField("meta", map[string]string{"field": "value"}).
+		File("file", `"quoted".txt`, "", strings.NewReader("file content"))
+	if err := http.Do(request); err != nil {
+		t.Fatalf("Unexpected error '%v' making request", err)
+	}
+
+	expectedParts := []struct {
+		name, filename, contentType, content string
+	}{
+		{"name", "", "", "value"},
+		{"meta", "", "application/json", `{"field":"value"}` + "\n"},
+		{"file", `"quoted".txt`, "application/octet-stream", "file content"},
+	}
+	transport.AssertRequestBodyMultipart(func(reader *multipart.Reader) {
+		for _, expected := range expectedParts {
+			part, err := reader.NextPart()
+			if err != nil {
+				t.Fatalf("Failed to read part '%s': %v", expected.name, err)
+			}
+
+			if name := part.FormName(); name != expected.name {
+				t.Errorf("Expected part to have name '%s', but was '%s'", expected.name, name)
+			}
+
+			if filename := part.FileName(); filename != expected.filename {
+				t.Errorf("Expected part '%s' to have filename '%s', but was '%s'", expected.name, expected.filename, filename)
+			}
+
+			if contentType := part.Header.Get("Content-Type"); contentType != expected.contentType {
+				t.Errorf("Expected part '%s' to have content type '%s', but was '%s'", expected.name, expected.contentType, contentType)
+			}
+
+			if content, _ := ioutil.ReadAll(part); string(content) != expected.content {
+				t.Errorf("Expected part '%s' to have content '%s', but was '%s'", expected.name, expected.content, content)
+			}
+		}
+
+		if _, err := reader.NextPart(); err != io.EOF {
+			t.Errorf("Expected no further parts, but got %v", err)
+		}
+	})
+}
+
+type failingReader struct {
+	err error
+}
+
+func (reader *failingReader) Read([]byte) (int, error) {
+	return 0, reader.err
+}
+
+func TestMultipart_RequestFailsWhenAPartCannotBeRead(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	client, err := sling.NewHTTP(server.URL, sling.Config{})
+	if err != nil {
+		t.Fatalf("Unexpected error '%v' creating client", err)
+	}
+
+	readErr := errors.New("Failed to read file")
+	request := sling.MultipartRequest("POST", "").
+		File("file", "file.txt", "text/plain", &failingReader{readErr})
+	if err := client.Do(request); err == nil || !strings.Contains(err.Error(), readErr.Error()) {
+		t.Errorf("Expected request to fail with error '%v', but was '%v'", readErr, err)
+	}
+}
@@ -0,0 +1,84 @@
+package sling
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// ErrPinMismatch is returned for requests to a pinned host whose certificate
+// chain contains none of its pinned public keys.
+type ErrPinMismatch struct {
+	// Host is the name of the pinned host.
+	Host string
+
+	// Pins are the pins of the certificates presented by the host.
+	Pins []string
+}
+
+func (err *ErrPinMismatch) Error() string {
+	return fmt.Sprintf("Certificate chain of %s matches none of its pins, got %s", err.Host, strings.Join(err.Pins, ", "))
+}
+
+// SPKIPin returns the pin of certificate, which is the base64 encoded
+// SHA-256 hash of its DER encoded SubjectPublicKeyInfo as produced by
+//
+//	openssl x509 -pubkey -noout | openssl pkey -pubin -outform der |
+//	    openssl dgst -sha256 -binary | base64
+func SPKIPin(certificate *x509.Certificate) string {
+	sum := sha256.Sum256(certificate.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// verifyPins returns a function verifying that a connection to a host of
+// pins presented a certificate matching one of them, or nil if there are
+// no pins.
+func verifyPins(pins map[string][]string) func(tls.ConnectionState) error {
+	if len(pins) == 0 {
+		return nil
+	}
+
+	accepted := make(map[string]map[string]bool, len(pins))
+	for host, hostPins := range pins {
+		host = strings.ToLower(host)
+		if accepted[host] == nil {
+			accepted[host] = make(map[string]bool, len(hostPins))
+		}
+		for _, pin := range hostPins {
+			accepted[host][pin] = true
+		}
+	}
+
+	return func(state tls.ConnectionState) error {
+		host := strings.ToLower(state.ServerName)
+		hostPins, ok := accepted[host]
+		if !ok {
+			return nil
+		}
+
+		// Without SkipSSLValidation only certificates of verified chains
+		// may match. Otherwise only the leaf may match, as the handshake
+		// proves nothing but the possession of its key and any other
+		// certificate could simply be appended by an attacker.
+		certificates := state.PeerCertificates[:min(len(state.PeerCertificates), 1)]
+		if len(state.VerifiedChains) > 0 {
+			certificates = nil
+			for _, chain := range state.VerifiedChains {
+				certificates = append(certificates, chain...)
+			}
+		}
+
+		presented := make([]string, 0, len(certificates))
+		for _, certificate := range certificates {
+			pin := SPKIPin(certificate)
+			if hostPins[pin] {
+				return nil
+			}
+			presented = append(presented, pin)
+		}
+		return &ErrPinMismatch{Host: host, Pins: presented}
+	}
+}
@@ -0,0 +1,157 @@
+package sling
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newPinnedClient creates a client for the TLS server which sends requests
+// to it as example.com, the name of its certificate, using pins.
+func newPinnedClient(server *httptest.Server, pins map[string][]string) HTTP {
+	client, _ := NewConnectionPool(Config{
+		SkipSSLValidation: true,
+		Pins:              pins,
+		Dialer: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return new(net.Dialer).DialContext(ctx, network, server.Listener.Addr().String())
+		},
+	}).HTTP("https://example.com")
+	return client
+}
+
+func newPinnedServer() *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	}))
+}
+
+// newCertificate creates a certificate for example.com signed by parent,
+// or a self-signed one if parent is nil.
+func newCertificate(t *testing.T, isCA bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "example.com"},
+		DNSNames:              []string{"example.com"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("Unexpected error creating certificate: %v", err)
+	}
+	certificate, _ := x509.ParseCertificate(der)
+	return certificate, key
+}
+
+func TestPins_MatchingPinsAreAccepted(t *testing.T) {
+	server := newPinnedServer()
+	defer server.Close()
+
+	pin := SPKIPin(server.Certificate())
+	client := newPinnedClient(server, map[string][]string{
+		"Example.com": {"47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=", pin},
+	})
+	if err := client.Do(JSONRequest("GET", "")); err != nil {
+		t.Errorf("Unexpected error with a matching pin: %v", err)
+	}
+}
+
+func TestPins_MismatchesFail(t *testing.T) {
+	server := newPinnedServer()
+	defer server.Close()
+
+	client := newPinnedClient(server, map[string][]string{
+		"example.com": {"47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="},
+	})
+
+	var mismatch *ErrPinMismatch
+	err := client.Do(JSONRequest("GET", ""))
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("Expected a pin mismatch, but got %v", err)
+	}
+	if mismatch.Host != "example.com" {
+		t.Errorf("Expected mismatch of host %q, but got %q", "example.com", mismatch.Host)
+	}
+	if pin := SPKIPin(server.Certificate()); len(mismatch.Pins) != 1 || mismatch.Pins[0] != pin {
+		t.Errorf("Expected the presented pins to be %v, but got %v", []string{pin}, mismatch.Pins)
+	}
+}
+
+func TestPins_AppendedCertificatesCannotMatchWithoutValidation(t *testing.T) {
+	pinned, _ := newCertificate(t, false, nil, nil)
+	other, otherKey := newCertificate(t, false, nil, nil)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{{
+		Certificate: [][]byte{other.Raw, pinned.Raw},
+		PrivateKey:  otherKey,
+	}}}
+	server.StartTLS()
+	defer server.Close()
+
+	client := newPinnedClient(server, map[string][]string{"example.com": {SPKIPin(pinned)}})
+
+	var mismatch *ErrPinMismatch
+	if err := client.Do(JSONRequest("GET", "")); !errors.As(err, &mismatch) {
+		t.Errorf("Expected a pin mismatch for an appended certificate, but got %v", err)
+	}
+}
+
+func TestPins_CertificatesOfVerifiedChainsMayMatch(t *testing.T) {
+	ca, caKey := newCertificate(t, true, nil, nil)
+	leaf, _ := newCertificate(t, false, ca, caKey)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	chains, err := leaf.Verify(x509.VerifyOptions{Roots: roots, DNSName: "example.com"})
+	if err != nil {
+		t.Fatalf("Unexpected error verifying certificate: %v", err)
+	}
+	state := tls.ConnectionState{ServerName: "example.com", PeerCertificates: []*x509.Certificate{leaf}, VerifiedChains: chains}
+
+	if err := verifyPins(map[string][]string{"example.com": {SPKIPin(ca)}})(state); err != nil {
+		t.Errorf("Unexpected error with the CA pinned: %v", err)
+	}
+
+	var mismatch *ErrPinMismatch
+	other, _ := newCertificate(t, true, nil, nil)
+	if err := verifyPins(map[string][]string{"example.com": {SPKIPin(other)}})(state); !errors.As(err, &mismatch) {
+		t.Errorf("Expected a pin mismatch, but got %v", err)
+	} else if len(mismatch.Pins) != 2 {
+		t.Errorf("Expected the pins of the verified chain, but got %v", mismatch.Pins)
+	}
+}
+
+func TestPins_HostsWithoutPinsAreNotChecked(t *testing.T) {
+	server := newPinnedServer()
+	defer server.Close()
+
+	client := newPinnedClient(server, map[string][]string{
+		"payments.example.com": {"47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="},
+	})
+	if err := client.Do(JSONRequest("GET", "")); err != nil {
+		t.Errorf("Unexpected error for a host without pins: %v", err)
+	}
+}
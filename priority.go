@@ -0,0 +1,54 @@
+package sling
+
+import (
+	"context"
+	"net/http"
+)
+
+// Priority is the class of a request when waiting for a slot of its pool,
+// requests of higher priority are granted slots first.
+type Priority int
+
+const (
+	// PriorityLow is intended for background and batch requests.
+	PriorityLow Priority = iota - 1
+
+	// PriorityNormal is the priority of requests which have none set.
+	PriorityNormal
+
+	// PriorityHigh is intended for user facing requests.
+	PriorityHigh
+)
+
+const numPriorities = 3
+
+// index returns the position of priority in per class arrays, with values
+// out of range being treated as the nearest valid priority.
+func (priority Priority) index() int {
+	switch {
+	case priority < PriorityLow:
+		return 0
+	case priority > PriorityHigh:
+		return numPriorities - 1
+	default:
+		return int(priority - PriorityLow)
+	}
+}
+
+type priorityKey struct{}
+
+// WithPriority returns a copy of ctx carrying priority, which is used for
+// requests made with the returned context unless they set their own.
+func WithPriority(ctx context.Context, priority Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+func priorityFrom(req *http.Request) Priority {
+	if priority := requestOptionsFrom(req).priority; priority != nil {
+		return *priority
+	}
+	if priority, ok := req.Context().Value(priorityKey{}).(Priority); ok {
+		return priority
+	}
+	return PriorityNormal
+}
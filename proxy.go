@@ -0,0 +1,174 @@
+package sling
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Proxy configures the outbound proxy of a pool.
+//
+// Requests are sent through URL if set, or otherwise through the proxies
+// given by the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables
+// if FromEnvironment is true. Requests to hosts matching NoProxy are
+// always sent directly.
+type Proxy struct {
+	// URL is the http, https or socks5 URL of the proxy. Credentials for
+	// the proxy may be given in its userinfo.
+	URL string
+
+	// Credentials overrides the credentials of URL if set, supplying the
+	// Username and Password of the proxy.
+	Credentials CredentialProvider
+
+	// FromEnvironment uses the proxies configured by the environment if
+	// URL is empty.
+	FromEnvironment bool
+
+	// NoProxy contains the hosts which are not proxied, using the syntax
+	// of NO_PROXY entries: host names also match their subdomains, and
+	// only match subdomains if they start with a dot, IP addresses and
+	// CIDR ranges match hosts given as addresses, a port restricts the
+	// entry to that port, and "*" matches all hosts.
+	NoProxy []string
+}
+
+// ErrUnsupportedProxy is returned when creating clients of a pool whose
+// proxy URL is invalid or has an unsupported scheme.
+var ErrUnsupportedProxy = errors.New("Only http, https and socks5 proxies are supported")
+
+// newProxyFunc returns a function choosing the proxy for each request of
+// a http.Transport as configured by proxy, which may be nil.
+func newProxyFunc(proxy *Proxy) func(*http.Request) (*url.URL, error) {
+	if proxy == nil {
+		return nil
+	}
+
+	rules := parseNoProxy(proxy.NoProxy)
+	if proxy.URL == "" {
+		if !proxy.FromEnvironment {
+			return nil
+		}
+		return func(req *http.Request) (*url.URL, error) {
+			if rules.matches(req.URL) {
+				return nil, nil
+			}
+			return http.ProxyFromEnvironment(req)
+		}
+	}
+
+	proxyURL, err := parseProxyURL(proxy.URL)
+	if err != nil {
+		return func(*http.Request) (*url.URL, error) {
+			return nil, err
+		}
+	}
+
+	return func(req *http.Request) (*url.URL, error) {
+		if rules.matches(req.URL) {
+			return nil, nil
+		}
+		if proxy.Credentials == nil {
+			return proxyURL, nil
+		}
+
+		credentials, err := proxy.Credentials.Credentials()
+		if err != nil {
+			return nil, err
+		}
+		withCredentials := *proxyURL
+		withCredentials.User = url.UserPassword(credentials.Username, credentials.Password)
+		return &withCredentials, nil
+	}
+}
+
+// parseProxyURL parses the URL of a proxy, failing with ErrUnsupportedProxy
+// if it is invalid or has an unsupported scheme.
+func parseProxyURL(rawURL string) (*url.URL, error) {
+	proxyURL, err := url.Parse(rawURL)
+	if err != nil || (proxyURL.Scheme != "http" && proxyURL.Scheme != "https" && proxyURL.Scheme != "socks5") {
+		return nil, ErrUnsupportedProxy
+	}
+	return proxyURL, nil
+}
+
+// noProxyRule is a parsed NO_PROXY entry.
+type noProxyRule struct {
+	all            bool
+	domain         string
+	subdomainsOnly bool
+	network        *net.IPNet
+	port           string
+}
+
+type noProxyRules []noProxyRule
+
+func parseNoProxy(entries []string) noProxyRules {
+	rules := make(noProxyRules, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			rules = append(rules, noProxyRule{all: true})
+			continue
+		}
+
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			rules = append(rules, noProxyRule{network: network})
+			continue
+		}
+
+		var rule noProxyRule
+		if host, port, err := net.SplitHostPort(entry); err == nil {
+			entry, rule.port = host, port
+		}
+		if ip := net.ParseIP(strings.Trim(entry, "[]")); ip != nil {
+			bits := 8 * len(ip)
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 32
+			}
+			rule.network = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+		} else {
+			rule.subdomainsOnly = strings.HasPrefix(entry, ".")
+			rule.domain = strings.TrimPrefix(entry, ".")
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// matches returns whether target should not be proxied.
+func (rules noProxyRules) matches(target *url.URL) bool {
+	host, port := strings.ToLower(target.Hostname()), target.Port()
+	if port == "" {
+		port = "80"
+		if target.Scheme == "https" {
+			port = "443"
+		}
+	}
+	ip := net.ParseIP(host)
+
+	for _, rule := range rules {
+		switch {
+		case rule.all:
+			return true
+		case rule.port != "" && rule.port != port:
+		case rule.network != nil:
+			if ip != nil && rule.network.Contains(ip) {
+				return true
+			}
+		case ip != nil:
+		case host == rule.domain:
+			if !rule.subdomainsOnly {
+				return true
+			}
+		case strings.HasSuffix(host, "."+rule.domain):
+			return true
+		}
+	}
+	return false
+}
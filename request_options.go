@@ -0,0 +1,37 @@
+package sling
+
+import (
+	"context"
+	"net/http"
+)
+
+// requestOptions holds per request settings of the request builders, which
+// are passed along with the built HTTP request to the clients executing it.
+type requestOptions struct {
+	// compression is the content encoding used for the request body,
+	// "identity" disables compression.
+	compression string
+
+	// trace receives the connection diagnostics of the request if set.
+	trace *TraceResult
+
+	// key identifies the request for balancing, see Balancer.
+	key string
+
+	// priority overrides the priority of the request's context if set.
+	priority *Priority
+
+	// timeouts overrides the pool's Timeouts if set.
+	timeouts *Timeouts
+}
+
+type requestOptionsKey struct{}
+
+func withRequestOptions(req *http.Request, options requestOptions) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), requestOptionsKey{}, options))
+}
+
+func requestOptionsFrom(req *http.Request) requestOptions {
+	options, _ := req.Context().Value(requestOptionsKey{}).(requestOptions)
+	return options
+}
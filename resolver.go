@@ -0,0 +1,298 @@
+package sling
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ServiceScheme is the scheme of base URLs naming a service whose endpoints
+// are looked up by the pool's Resolver, such as service://payments/api/.
+//
+// The path of such a base URL is appended to the base URLs of each of the
+// service's endpoints.
+const ServiceScheme = "service"
+
+// DefaultResolveTTL is the default time after which services are
+// resolved again.
+const DefaultResolveTTL = 30 * time.Second
+
+// ErrNoResolver is returned when creating a client for a service base URL
+// from a pool without a Resolver.
+var ErrNoResolver = errors.New("Service base URLs require a Resolver")
+
+// ErrServiceNotFound is returned by Resolvers for services without any
+// endpoints.
+var ErrServiceNotFound = errors.New("Service has no endpoints")
+
+// Resolver implementations look up the base URLs of the endpoints of a
+// service, they must be safe for concurrent use.
+//
+// Services are resolved when a client is created for them, and then again
+// once the returned TTL has elapsed, or DefaultResolveTTL if it is less than
+// or equal to 0. If resolving fails, the previous endpoints are kept.
+type Resolver interface {
+	Resolve(ctx context.Context, service string) (baseURLs []string, ttl time.Duration, err error)
+}
+
+// StaticResolver returns a Resolver which resolves each service to the
+// given base URLs, it is useful for tests and local development.
+func StaticResolver(services map[string][]string) Resolver {
+	return staticResolver(services)
+}
+
+type staticResolver map[string][]string
+
+func (services staticResolver) Resolve(ctx context.Context, service string) ([]string, time.Duration, error) {
+	baseURLs := services[service]
+	if len(baseURLs) == 0 {
+		return nil, 0, ErrServiceNotFound
+	}
+	return baseURLs, 0, nil
+}
+
+// FileResolver returns a Resolver reading services from the JSON file at
+// path, which maps each service to its base URLs, such as
+//
+//	{"payments": ["http://10.0.0.1:8080", "http://10.0.0.2:8080"]}
+//
+// The file is read every time services are resolved, which happens after
+// ttl or DefaultResolveTTL if it is less than or equal to 0.
+func FileResolver(path string, ttl time.Duration) Resolver {
+	return &fileResolver{path: path, ttl: ttl}
+}
+
+type fileResolver struct {
+	path string
+	ttl  time.Duration
+}
+
+func (resolver *fileResolver) Resolve(ctx context.Context, service string) ([]string, time.Duration, error) {
+	content, err := ioutil.ReadFile(resolver.path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var services map[string][]string
+	if err := json.Unmarshal(content, &services); err != nil {
+		return nil, 0, err
+	}
+	baseURLs, _, err := staticResolver(services).Resolve(ctx, service)
+	return baseURLs, resolver.ttl, err
+}
+
+// SRVResolver resolves services using DNS SRV records, services being named
+// by their full record name such as _http._tcp.payments.example.com. Only the
+// records with the lowest priority value are used.
+type SRVResolver struct {
+	// Scheme is the scheme of the resolved base URLs, defaults to http.
+	Scheme string
+
+	// TTL is the time after which services are resolved again, defaults to
+	// DefaultResolveTTL if less than or equal to 0. The TTL of the records
+	// themselves is not available.
+	TTL time.Duration
+
+	// Resolver is used for the lookups, defaults to net.DefaultResolver.
+	Resolver *net.Resolver
+}
+
+func (resolver *SRVResolver) Resolve(ctx context.Context, service string) ([]string, time.Duration, error) {
+	lookup := resolver.Resolver
+	if lookup == nil {
+		lookup = net.DefaultResolver
+	}
+	scheme := resolver.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+
+	_, records, err := lookup.LookupSRV(ctx, "", "", service)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Records are sorted by priority, and a target of "." indicates that
+	// the service is unavailable.
+	var baseURLs []string
+	for _, record := range records {
+		if record.Priority != records[0].Priority {
+			break
+		}
+		if target := strings.TrimSuffix(record.Target, "."); target != "" {
+			baseURLs = append(baseURLs, scheme+"://"+net.JoinHostPort(target, strconv.Itoa(int(record.Port))))
+		}
+	}
+	if len(baseURLs) == 0 {
+		return nil, 0, ErrServiceNotFound
+	}
+	return baseURLs, resolver.TTL, nil
+}
+
+// service is a service base URL of a cluster along with its current
+// endpoints.
+type service struct {
+	name, path string
+	endpoints  []*clusterEndpoint
+}
+
+// parseServiceURL returns the service of a service base URL, as well as the
+// base URL against which requests to it are built, or nil for other base
+// URLs.
+func parseServiceURL(baseURL string) (*service, *url.URL, error) {
+	if !strings.HasPrefix(baseURL, ServiceScheme+"://") {
+		return nil, nil, nil
+	}
+
+	parsed, err := url.Parse(strings.TrimRight(baseURL, "/") + "/")
+	if err != nil {
+		return nil, nil, err
+	}
+	if parsed.Host == "" {
+		return nil, nil, errors.New("Service base URLs require a service name")
+	}
+
+	placeholder := *parsed
+	placeholder.Scheme = "http"
+	return &service{name: parsed.Host, path: parsed.Path}, &placeholder, nil
+}
+
+// serviceResolver resolves the services of a cluster again once their TTL
+// has elapsed, replacing the endpoints of its balancer until stopped.
+type serviceResolver struct {
+	pool     *pool
+	balancer *balancingHTTPClient
+	ctx      context.Context
+	stop     context.CancelFunc
+
+	// mutex guards the endpoints of services, which are no longer
+	// replaced once stopped.
+	mutex    sync.Mutex
+	stopped  bool
+	static   []*clusterEndpoint
+	services []*service
+}
+
+// newServiceResolver resolves the services of balancer, whose endpoints
+// are static, and keeps them up to date unless it fails.
+func newServiceResolver(pool *pool, balancer *balancingHTTPClient, services []*service) (*serviceResolver, error) {
+	if pool.Resolver == nil {
+		return nil, ErrNoResolver
+	}
+
+	resolver := &serviceResolver{
+		pool:     pool,
+		balancer: balancer,
+		static:   balancer.endpoints,
+		services: services,
+	}
+	resolver.ctx, resolver.stop = context.WithCancel(context.Background())
+
+	ttls := make([]time.Duration, len(services))
+	for i, service := range services {
+		ttl, err := resolver.resolve(service)
+		if err != nil {
+			resolver.Stop()
+			return nil, err
+		}
+		ttls[i] = ttl
+	}
+	for i, service := range services {
+		go resolver.watch(service, ttls[i])
+	}
+	return resolver, nil
+}
+
+func (resolver *serviceResolver) Stop() {
+	resolver.stop()
+
+	resolver.mutex.Lock()
+	resolver.stopped = true
+	resolver.mutex.Unlock()
+}
+
+// watch resolves service again whenever its ttl has elapsed.
+func (resolver *serviceResolver) watch(service *service, ttl time.Duration) {
+	timer := time.NewTimer(ttl)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+		case <-resolver.ctx.Done():
+			return
+		}
+
+		if next, err := resolver.resolve(service); err == nil {
+			ttl = next
+		}
+		timer.Reset(ttl)
+	}
+}
+
+// resolve updates the endpoints of service, keeping the health of those
+// which remain and releasing the others, and returns the time after which
+// it should be resolved again.
+func (resolver *serviceResolver) resolve(service *service) (time.Duration, error) {
+	baseURLs, ttl, err := resolver.pool.Resolver.Resolve(resolver.ctx, service.name)
+	if err == nil && len(baseURLs) == 0 {
+		err = ErrServiceNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	parsed := make([]*url.URL, len(baseURLs))
+	for i, baseURL := range baseURLs {
+		if parsed[i], err = resolver.pool.parseEndpointURL(strings.TrimRight(baseURL, "/") + service.path); err != nil {
+			return 0, err
+		}
+	}
+
+	resolver.mutex.Lock()
+	defer resolver.mutex.Unlock()
+	if resolver.stopped {
+		return 0, context.Canceled
+	}
+
+	previous := make(map[string]*clusterEndpoint, len(service.endpoints))
+	for _, endpoint := range service.endpoints {
+		previous[endpoint.URL.String()] = endpoint
+	}
+	service.endpoints = nil
+	for _, baseURL := range parsed {
+		key := baseURL.String()
+		endpoint, ok := previous[key]
+		if !ok {
+			endpoint = resolver.balancer.newEndpoint(resolver.pool.endpoint(baseURL))
+		}
+		if endpoint != nil {
+			service.endpoints = append(service.endpoints, endpoint)
+			previous[key] = nil
+		}
+	}
+
+	endpoints := append([]*clusterEndpoint(nil), resolver.static...)
+	for _, service := range resolver.services {
+		endpoints = append(endpoints, service.endpoints...)
+	}
+	resolver.balancer.setEndpoints(endpoints)
+
+	for _, endpoint := range previous {
+		if endpoint != nil {
+			resolver.pool.releaseEndpoint(endpoint.endpoint)
+		}
+	}
+
+	if ttl <= 0 {
+		ttl = DefaultResolveTTL
+	}
+	return ttl, nil
+}
@@ -0,0 +1,215 @@
+package sling_test
+
+import (
+	"context"
+	"errors"
+	"golang.struktur.de/sling"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// changingResolver resolves every service to its current base URLs, or
+// fails with its current error.
+type changingResolver struct {
+	sync.Mutex
+	baseURLs []string
+	err      error
+}
+
+func (resolver *changingResolver) set(baseURLs []string, err error) {
+	resolver.Lock()
+	defer resolver.Unlock()
+	resolver.baseURLs, resolver.err = baseURLs, err
+}
+
+func (resolver *changingResolver) Resolve(ctx context.Context, service string) ([]string, time.Duration, error) {
+	resolver.Lock()
+	defer resolver.Unlock()
+	return resolver.baseURLs, 5 * time.Millisecond, resolver.err
+}
+
+// newNamedServer starts a server responding with its name and the path of
+// each request.
+func newNamedServer(name string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"name": "` + name + `", "path": "` + r.URL.Path + `"}`))
+	}))
+}
+
+type namedResponse struct {
+	Name, Path string
+}
+
+func TestResolver_ServicesAreResolved(t *testing.T) {
+	server := newNamedServer("payments")
+	defer server.Close()
+
+	pool := sling.NewConnectionPool(sling.Config{
+		Resolver: sling.StaticResolver(map[string][]string{"payments": {server.URL}}),
+	})
+	client, err := pool.HTTP("service://payments/api")
+	if err != nil {
+		t.Fatalf("Unexpected error creating client: %v", err)
+	}
+	cluster, ok := client.(sling.Cluster)
+	if !ok {
+		t.Fatal("Expected the client of a service to be a Cluster")
+	}
+	defer cluster.Close()
+
+	var response namedResponse
+	if err := client.Do(sling.JSONRequest("GET", "charges").Success(&response)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if response.Name != "payments" || response.Path != "/api/charges" {
+		t.Errorf("Expected request to /api/charges of payments, but got %+v", response)
+	}
+}
+
+func TestResolver_ServicesAreResolvedAgainAfterTheirTTL(t *testing.T) {
+	first, second := newNamedServer("first"), newNamedServer("second")
+	defer first.Close()
+	defer second.Close()
+
+	resolver := &changingResolver{baseURLs: []string{first.URL}}
+	cluster, err := sling.NewConnectionPool(sling.Config{Resolver: resolver}).HTTPCluster([]string{"service://payments"})
+	if err != nil {
+		t.Fatalf("Unexpected error creating cluster: %v", err)
+	}
+	defer cluster.Close()
+
+	resolver.set([]string{second.URL}, nil)
+	deadline := time.Now().Add(time.Second)
+	var response namedResponse
+	for response.Name != "second" && time.Now().Before(deadline) {
+		if err := cluster.Do(sling.JSONRequest("GET", "").Success(&response)); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if response.Name != "second" {
+		t.Fatal("Expected requests to be sent to the new endpoint")
+	}
+	if endpoints := cluster.Endpoints(); len(endpoints) != 1 || endpoints[0].URL != second.URL+"/" {
+		t.Errorf("Expected the only endpoint to be %s, but got %+v", second.URL+"/", endpoints)
+	}
+}
+
+func TestResolver_EndpointsNoLongerUsedAreReleased(t *testing.T) {
+	first, second := newNamedServer("first"), newNamedServer("second")
+	defer first.Close()
+	defer second.Close()
+
+	resolver := &changingResolver{baseURLs: []string{first.URL}}
+	pool := sling.NewConnectionPool(sling.Config{Resolver: resolver, TraceConnections: true})
+	cluster, err := pool.HTTPCluster([]string{"service://payments"})
+	if err != nil {
+		t.Fatalf("Unexpected error creating cluster: %v", err)
+	}
+	if err := cluster.Do(sling.JSONRequest("GET", "")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	resolver.set([]string{second.URL}, nil)
+	deadline := time.Now().Add(time.Second)
+	var response namedResponse
+	for response.Name != "second" && time.Now().Before(deadline) {
+		if err := cluster.Do(sling.JSONRequest("GET", "").Success(&response)); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if connections := pool.Stats().Connections; len(connections) != 1 {
+		t.Errorf("Expected only the stats of the resolved endpoint to remain, but got %v", connections)
+	}
+
+	cluster.Close()
+	if connections := pool.Stats().Connections; len(connections) != 0 {
+		t.Errorf("Expected no stats to remain once the cluster was closed, but got %v", connections)
+	}
+}
+
+func TestResolver_EndpointsAreKeptIfResolvingFails(t *testing.T) {
+	server := newNamedServer("payments")
+	defer server.Close()
+
+	resolver := &changingResolver{baseURLs: []string{server.URL}}
+	cluster, _ := sling.NewConnectionPool(sling.Config{Resolver: resolver}).HTTPCluster([]string{"service://payments"})
+	defer cluster.Close()
+
+	resolver.set(nil, errors.New("Registry unavailable"))
+	time.Sleep(20 * time.Millisecond)
+	if err := cluster.Do(sling.JSONRequest("GET", "")); err != nil {
+		t.Errorf("Unexpected error after resolving failed: %v", err)
+	}
+}
+
+func TestResolver_ServicesAndBaseURLsMayBeCombined(t *testing.T) {
+	static, resolved := newNamedServer("static"), newNamedServer("resolved")
+	defer static.Close()
+	defer resolved.Close()
+
+	pool := sling.NewConnectionPool(sling.Config{
+		Resolver: sling.StaticResolver(map[string][]string{"payments": {resolved.URL}}),
+	})
+	cluster, err := pool.HTTPCluster([]string{"service://payments/api", static.URL + "/api"})
+	if err != nil {
+		t.Fatalf("Unexpected error creating cluster: %v", err)
+	}
+	defer cluster.Close()
+
+	names := make(map[string]bool)
+	for i := 0; i < 2; i++ {
+		var response namedResponse
+		if err := cluster.Do(sling.JSONRequest("GET", "charges").Success(&response)); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if response.Path != "/api/charges" {
+			t.Errorf("Expected request to /api/charges, but got %s", response.Path)
+		}
+		names[response.Name] = true
+	}
+	if !names["static"] || !names["resolved"] {
+		t.Errorf("Expected requests to be balanced across both endpoints, but got %v", names)
+	}
+}
+
+func TestResolver_CreatingClientsFailsIfServicesCannotBeResolved(t *testing.T) {
+	if _, err := sling.NewConnectionPool(sling.Config{}).HTTP("service://payments"); err != sling.ErrNoResolver {
+		t.Errorf("Expected %v without a resolver, but got %v", sling.ErrNoResolver, err)
+	}
+
+	pool := sling.NewConnectionPool(sling.Config{Resolver: sling.StaticResolver(nil)})
+	if _, err := pool.HTTP("service://payments"); err != sling.ErrServiceNotFound {
+		t.Errorf("Expected %v for an unknown service, but got %v", sling.ErrServiceNotFound, err)
+	}
+}
+
+func TestResolver_FileResolverReadsServicesFromFile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "sling")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "services.json")
+	ioutil.WriteFile(path, []byte(`{"payments": ["http://10.0.0.1:8080", "http://10.0.0.2:8080"]}`), 0600)
+
+	resolver := sling.FileResolver(path, time.Minute)
+	baseURLs, ttl, err := resolver.Resolve(context.Background(), "payments")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(baseURLs) != 2 || baseURLs[1] != "http://10.0.0.2:8080" || ttl != time.Minute {
+		t.Errorf("Unexpected resolution %v with TTL %v", baseURLs, ttl)
+	}
+
+	if _, _, err := resolver.Resolve(context.Background(), "accounts"); err != sling.ErrServiceNotFound {
+		t.Errorf("Expected %v for an unknown service, but got %v", sling.ErrServiceNotFound, err)
+	}
+
+	os.Remove(path)
+	if _, _, err := resolver.Resolve(context.Background(), "payments"); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
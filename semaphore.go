@@ -0,0 +1,267 @@
+package sling
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrPoolSaturated is returned for requests which could not be queued
+// for a slot of their pool, or waited for longer than allowed.
+var ErrPoolSaturated = errors.New("Connection pool is saturated")
+
+// QueueStats contains the state of the queues of requests waiting
+// for a slot.
+type QueueStats struct {
+	// Depth is the number of requests currently waiting.
+	Depth int64
+
+	// Shed is the number of requests which failed with ErrPoolSaturated.
+	Shed int64
+}
+
+type queueStats QueueStats
+
+func (stats *queueStats) snapshot() QueueStats {
+	return QueueStats{
+		Depth: atomic.LoadInt64(&stats.Depth),
+		Shed:  atomic.LoadInt64(&stats.Shed),
+	}
+}
+
+// semaphore limits the number of concurrently held slots, granting waiting
+// requests slots in order of their priority.
+//
+// Slots may be reserved for a priority class, in which case other classes
+// may not use them. Waiters which have waited longer than the starvation
+// timeout are granted slots before all others, oldest first.
+//
+// If maxWaiting or maxWait are greater than 0, requests which would exceed
+// the number of waiters or wait for too long fail with ErrPoolSaturated.
+type semaphore struct {
+	sync.Mutex
+	limit             int
+	inUse             int
+	inUseBy           [numPriorities]int
+	reserved          [numPriorities]int
+	waiting           [numPriorities][]*waiter
+	starvationTimeout time.Duration
+	maxWaiting        int
+	maxWait           time.Duration
+	stats             *queueStats
+}
+
+type waiter struct {
+	priority Priority
+	since    time.Time
+	ready    chan nothing
+	granted  bool
+}
+
+type nothing struct{}
+
+func newSemaphore(limit int) *semaphore {
+	return &semaphore{limit: limit, stats: new(queueStats)}
+}
+
+// Lock waits until a slot is available for priority, or fails with the
+// context's error if it is done first.
+func (s *semaphore) Lock(ctx context.Context, priority Priority) error {
+	return s.lockSince(ctx, priority, time.Now())
+}
+
+// lockSince is like Lock for a request which has been waiting since the
+// given time, which counts towards its starvation and maximum wait.
+func (s *semaphore) lockSince(ctx context.Context, priority Priority, since time.Time) error {
+	s.Mutex.Lock()
+	if !s.hasWaitersFor(priority) && s.canGrant(priority) {
+		s.grant(priority)
+		s.Mutex.Unlock()
+		return nil
+	}
+
+	if s.maxWaiting > 0 && s.waitingCount() >= s.maxWaiting {
+		s.Mutex.Unlock()
+		atomic.AddInt64(&s.stats.Shed, 1)
+		return ErrPoolSaturated
+	}
+
+	w := &waiter{priority: priority, since: since, ready: make(chan nothing)}
+	index := priority.index()
+	s.waiting[index] = append(s.waiting[index], w)
+	atomic.AddInt64(&s.stats.Depth, 1)
+	s.dispatch()
+	s.Mutex.Unlock()
+
+	var timeout <-chan time.Time
+	if s.maxWait > 0 {
+		timer := time.NewTimer(s.maxWait - time.Since(since))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		return s.abandon(w, ctx.Err())
+	case <-timeout:
+		atomic.AddInt64(&s.stats.Shed, 1)
+		return s.abandon(w, ErrPoolSaturated)
+	}
+}
+
+// abandon stops w from waiting, releasing its slot if it was granted one
+// in the meantime, and returns err.
+func (s *semaphore) abandon(w *waiter, err error) error {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	if w.granted {
+		s.release(w.priority)
+	} else {
+		s.remove(w)
+	}
+	return err
+}
+
+// Unlock releases a slot held for priority.
+func (s *semaphore) Unlock(priority Priority) {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	s.release(priority)
+}
+
+// Limit returns the current number of slots.
+func (s *semaphore) Limit() int {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	return s.limit
+}
+
+// capacity returns the number of slots which requests of priority may hold
+// at once, which excludes the reservations of other classes.
+func (s *semaphore) capacity(priority Priority) int {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	capacity := s.limit
+	for index, reserved := range s.reserved {
+		if index != priority.index() {
+			capacity -= reserved
+		}
+	}
+	return capacity
+}
+
+// SetLimit changes the number of slots to limit. When shrinking, slots in
+// use above the new limit remain held until they are unlocked.
+func (s *semaphore) SetLimit(limit int) {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	s.limit = limit
+	s.dispatch()
+}
+
+// InUse returns the number of slots currently held.
+func (s *semaphore) InUse() int {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	return s.inUse
+}
+
+// hasWaitersFor returns whether any waiter would be granted a slot
+// before a request of priority.
+func (s *semaphore) hasWaitersFor(priority Priority) bool {
+	for index := priority.index(); index < numPriorities; index++ {
+		if len(s.waiting[index]) > 0 {
+			return true
+		}
+	}
+	return s.oldestStarving() != nil
+}
+
+// canGrant returns whether a slot is available for priority without using
+// the unused reservations of other classes.
+func (s *semaphore) canGrant(priority Priority) bool {
+	free := s.limit - s.inUse
+	for index := range s.reserved {
+		if index != priority.index() && s.reserved[index] > s.inUseBy[index] {
+			free -= s.reserved[index] - s.inUseBy[index]
+		}
+	}
+	return free > 0
+}
+
+func (s *semaphore) grant(priority Priority) {
+	s.inUse++
+	s.inUseBy[priority.index()]++
+}
+
+func (s *semaphore) release(priority Priority) {
+	s.inUse--
+	s.inUseBy[priority.index()]--
+	s.dispatch()
+}
+
+func (s *semaphore) waitingCount() int {
+	count := 0
+	for _, waiting := range s.waiting {
+		count += len(waiting)
+	}
+	return count
+}
+
+func (s *semaphore) remove(w *waiter) {
+	index := w.priority.index()
+	for i, candidate := range s.waiting[index] {
+		if candidate == w {
+			s.waiting[index] = append(s.waiting[index][:i], s.waiting[index][i+1:]...)
+			atomic.AddInt64(&s.stats.Depth, -1)
+			return
+		}
+	}
+}
+
+// oldestStarving returns the longest waiting waiter if it has waited for
+// longer than the starvation timeout.
+func (s *semaphore) oldestStarving() *waiter {
+	if s.starvationTimeout <= 0 {
+		return nil
+	}
+
+	var oldest *waiter
+	for _, waiting := range s.waiting {
+		if len(waiting) > 0 && (oldest == nil || waiting[0].since.Before(oldest.since)) {
+			oldest = waiting[0]
+		}
+	}
+	if oldest == nil || time.Since(oldest.since) < s.starvationTimeout {
+		return nil
+	}
+	return oldest
+}
+
+// dispatch grants slots to waiters for as long as possible, starving
+// waiters first and then in order of priority.
+func (s *semaphore) dispatch() {
+	for s.inUse < s.limit {
+		next := s.oldestStarving()
+		if next == nil || !s.canGrant(next.priority) {
+			next = nil
+			for index := numPriorities - 1; index >= 0 && next == nil; index-- {
+				if len(s.waiting[index]) > 0 && s.canGrant(s.waiting[index][0].priority) {
+					next = s.waiting[index][0]
+				}
+			}
+		}
+		if next == nil {
+			return
+		}
+
+		s.remove(next)
+		s.grant(next.priority)
+		next.granted = true
+		close(next.ready)
+	}
+}
@@ -0,0 +1,142 @@
+package sling
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// lockAsync locks s for priority on its own goroutine, sending priority
+// to granted once it succeeded.
+func lockAsync(s *semaphore, priority Priority, granted chan<- Priority) {
+	go func() {
+		if err := s.Lock(context.Background(), priority); err == nil {
+			granted <- priority
+		}
+	}()
+}
+
+// waitForWaiters waits until count requests are waiting for s.
+func waitForWaiters(t *testing.T, s *semaphore, count int) {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		s.Mutex.Lock()
+		waiting := s.waitingCount()
+		s.Mutex.Unlock()
+		if waiting == count {
+			return
+		}
+	}
+	t.Fatalf("Expected %d requests to be waiting", count)
+}
+
+func TestSemaphore_GrantsSlotsByPriority(t *testing.T) {
+	s := newSemaphore(1)
+	s.Lock(context.Background(), PriorityNormal)
+
+	granted := make(chan Priority, 3)
+	lockAsync(s, PriorityLow, granted)
+	waitForWaiters(t, s, 1)
+	lockAsync(s, PriorityNormal, granted)
+	waitForWaiters(t, s, 2)
+	lockAsync(s, PriorityHigh, granted)
+	waitForWaiters(t, s, 3)
+
+	holder := PriorityNormal
+	for _, expected := range []Priority{PriorityHigh, PriorityNormal, PriorityLow} {
+		s.Unlock(holder)
+		if holder = <-granted; holder != expected {
+			t.Errorf("Expected a slot to be granted to priority %d, but was %d", expected, holder)
+		}
+	}
+}
+
+func TestSemaphore_ReservedSlotsAreOnlyUsedByTheirPriority(t *testing.T) {
+	s := newSemaphore(2)
+	s.reserved[PriorityHigh.index()] = 1
+
+	if err := s.Lock(context.Background(), PriorityNormal); err != nil {
+		t.Fatalf("Unexpected error '%v' locking an unreserved slot", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.Lock(ctx, PriorityLow); err != context.DeadlineExceeded {
+		t.Errorf("Expected reserved slot to be unavailable, but got '%v'", err)
+	}
+
+	if err := s.Lock(context.Background(), PriorityHigh); err != nil {
+		t.Errorf("Unexpected error '%v' locking a reserved slot", err)
+	}
+}
+
+func TestSemaphore_StarvingRequestsAreGrantedFirst(t *testing.T) {
+	s := newSemaphore(1)
+	s.starvationTimeout = 20 * time.Millisecond
+	s.Lock(context.Background(), PriorityNormal)
+
+	granted := make(chan Priority, 2)
+	lockAsync(s, PriorityLow, granted)
+	waitForWaiters(t, s, 1)
+	time.Sleep(2 * s.starvationTimeout)
+	lockAsync(s, PriorityHigh, granted)
+	waitForWaiters(t, s, 2)
+
+	s.Unlock(PriorityNormal)
+	if priority := <-granted; priority != PriorityLow {
+		t.Errorf("Expected the starving request to be granted a slot, but priority %d was", priority)
+	}
+}
+
+func TestSemaphore_CancelledRequestsStopWaiting(t *testing.T) {
+	s := newSemaphore(1)
+	s.Lock(context.Background(), PriorityNormal)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error)
+	go func() {
+		result <- s.Lock(ctx, PriorityHigh)
+	}()
+	waitForWaiters(t, s, 1)
+	cancel()
+
+	if err := <-result; err != context.Canceled {
+		t.Errorf("Expected cancelled request to fail with '%v', but was '%v'", context.Canceled, err)
+	}
+
+	s.Unlock(PriorityNormal)
+	if s.inUse != 0 {
+		t.Errorf("Expected no slots to be in use, but %d were", s.inUse)
+	}
+}
+
+func TestSemaphore_RequestsBeyondMaxWaitingAreShed(t *testing.T) {
+	s := newSemaphore(1)
+	s.maxWaiting = 1
+	s.Lock(context.Background(), PriorityNormal)
+
+	granted := make(chan Priority, 1)
+	lockAsync(s, PriorityNormal, granted)
+	waitForWaiters(t, s, 1)
+
+	if err := s.Lock(context.Background(), PriorityHigh); err != ErrPoolSaturated {
+		t.Errorf("Expected request exceeding the queue length to fail with '%v', but was '%v'", ErrPoolSaturated, err)
+	}
+
+	if stats := s.stats.snapshot(); stats.Depth != 1 || stats.Shed != 1 {
+		t.Errorf("Expected one request to be waiting and one shed, but stats were %+v", stats)
+	}
+}
+
+func TestSemaphore_RequestsWaitingBeyondMaxWaitAreShed(t *testing.T) {
+	s := newSemaphore(1)
+	s.maxWait = 10 * time.Millisecond
+	s.Lock(context.Background(), PriorityNormal)
+
+	if err := s.Lock(context.Background(), PriorityNormal); err != ErrPoolSaturated {
+		t.Errorf("Expected request exceeding the queue wait to fail with '%v', but was '%v'", ErrPoolSaturated, err)
+	}
+
+	if stats := s.stats.snapshot(); stats.Depth != 0 || stats.Shed != 1 {
+		t.Errorf("Expected no requests to be waiting and one shed, but stats were %+v", stats)
+	}
+}
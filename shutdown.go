@@ -0,0 +1,71 @@
+package sling
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrPoolClosed is returned for requests made after their pool has been
+// shut down.
+var ErrPoolClosed = errors.New("Connection pool is closed")
+
+// lifecycle tracks the requests in flight on a pool, refusing new ones once
+// it has been closed.
+type lifecycle struct {
+	sync.Mutex
+	closed   bool
+	inFlight int
+	idle     chan nothing
+}
+
+func newLifecycle() *lifecycle {
+	return &lifecycle{idle: make(chan nothing)}
+}
+
+// acquire registers a request in flight, or fails with ErrPoolClosed.
+func (lifecycle *lifecycle) acquire() error {
+	lifecycle.Lock()
+	defer lifecycle.Unlock()
+	if lifecycle.closed {
+		return ErrPoolClosed
+	}
+	lifecycle.inFlight++
+	return nil
+}
+
+// isClosed returns whether the lifecycle has been closed.
+func (lifecycle *lifecycle) isClosed() bool {
+	lifecycle.Lock()
+	defer lifecycle.Unlock()
+	return lifecycle.closed
+}
+
+func (lifecycle *lifecycle) release() {
+	lifecycle.Lock()
+	defer lifecycle.Unlock()
+	lifecycle.inFlight--
+	if lifecycle.closed && lifecycle.inFlight == 0 {
+		close(lifecycle.idle)
+	}
+}
+
+// close refuses further requests and waits until those in flight have
+// finished, or fails with the context's error if it is done first.
+func (lifecycle *lifecycle) close(ctx context.Context) error {
+	lifecycle.Lock()
+	if !lifecycle.closed {
+		lifecycle.closed = true
+		if lifecycle.inFlight == 0 {
+			close(lifecycle.idle)
+		}
+	}
+	lifecycle.Unlock()
+
+	select {
+	case <-lifecycle.idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
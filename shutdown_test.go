@@ -0,0 +1,103 @@
+package sling_test
+
+import (
+	"context"
+	"golang.struktur.de/sling"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newBlockingServer starts a server whose responses are delayed until
+// release is closed, signalling each request received on started.
+func newBlockingServer() (server *httptest.Server, started chan struct{}, release chan struct{}) {
+	started, release = make(chan struct{}, 16), make(chan struct{})
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		w.Write([]byte("{}"))
+	}))
+	return server, started, release
+}
+
+func TestConnectionPool_ShutdownRefusesNewRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	pool := sling.NewConnectionPool(sling.Config{})
+	client, _ := pool.HTTP(server.URL)
+
+	if err := pool.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Unexpected error shutting down an idle pool: %v", err)
+	}
+
+	if err := client.Do(sling.JSONRequest("GET", "")); err != sling.ErrPoolClosed {
+		t.Errorf("Expected request to fail with %v, but got %v", sling.ErrPoolClosed, err)
+	}
+}
+
+func TestConnectionPool_ShutdownWaitsForRequestsInFlight(t *testing.T) {
+	server, started, release := newBlockingServer()
+	defer server.Close()
+
+	pool := sling.NewConnectionPool(sling.Config{})
+	client, _ := pool.HTTP(server.URL)
+
+	result := make(chan error, 1)
+	go func() {
+		result <- client.Do(sling.JSONRequest("GET", ""))
+	}()
+	<-started
+
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdown <- pool.Shutdown(context.Background())
+	}()
+
+	select {
+	case err := <-shutdown:
+		t.Fatalf("Expected shutdown to wait for the request in flight, but it returned %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-result; err != nil {
+		t.Errorf("Unexpected error for the request in flight: %v", err)
+	}
+	if err := <-shutdown; err != nil {
+		t.Errorf("Unexpected error shutting down: %v", err)
+	}
+}
+
+func TestConnectionPool_ShutdownFailsWhenContextExpires(t *testing.T) {
+	server, started, release := newBlockingServer()
+	defer server.Close()
+	defer close(release)
+
+	pool := sling.NewConnectionPool(sling.Config{})
+	client, _ := pool.HTTP(server.URL)
+
+	go client.Do(sling.JSONRequest("GET", ""))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := pool.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected shutdown to fail with %v, but got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestConnectionPool_ClustersCannotBeCreatedAfterShutdown(t *testing.T) {
+	pool := sling.NewConnectionPool(sling.Config{})
+	if err := pool.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Unexpected error shutting down an idle pool: %v", err)
+	}
+
+	_, err := pool.HTTPCluster([]string{"http://127.0.0.1:1"}, sling.WithHealthCheck(sling.HealthCheck{}))
+	if err != sling.ErrPoolClosed {
+		t.Errorf("Expected creating a cluster to fail with %v, but got %v", sling.ErrPoolClosed, err)
+	}
+}
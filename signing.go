@@ -0,0 +1,331 @@
+package sling
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Signer implementations sign requests once they have been fully built,
+// including any compression of their body, immediately before they are
+// sent to their endpoint.
+//
+// The body of each request is read into memory to be passed to Sign, even
+// if it is streamed, unless the Signer is a StreamingSigner.
+type Signer interface {
+	// Sign adds a signature to req, whose complete body is given.
+	Sign(req *http.Request, body []byte) error
+}
+
+// StreamingSigner is implemented by Signers able to sign requests without
+// reading their body, which is then streamed as it is sent. SignStream is
+// used for requests whose body can not be read again, such as those built
+// using Stream or RawBody, or with compression.
+type StreamingSigner interface {
+	Signer
+
+	// SignStream adds a signature to req which does not cover its body,
+	// or returns false if req must be signed using Sign instead.
+	SignStream(req *http.Request) (bool, error)
+}
+
+// WithSigner sets the Signer of a HTTP client or Cluster, overriding the
+// pool's Signer.
+func WithSigner(signer Signer) HTTPOption {
+	return func(options *httpOptions) {
+		options.signer = signer
+	}
+}
+
+// signingHTTPClient signs requests before sending them.
+type signingHTTPClient struct {
+	netHTTPClient
+	signer Signer
+}
+
+func (client *signingHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	req, err := signRequest(client.signer, req)
+	if err != nil {
+		return nil, err
+	}
+	return client.netHTTPClient.Do(req)
+}
+
+// signRequest returns a copy of req signed by signer, whose body is read
+// into memory so that it can be hashed unless it is streamed and signer
+// does not need it.
+func signRequest(signer Signer, req *http.Request) (*http.Request, error) {
+	if streamer, ok := signer.(StreamingSigner); ok && !isReplayable(req) {
+		streamed := req.Clone(req.Context())
+		signed, err := streamer.SignStream(streamed)
+		if err != nil {
+			req.Body.Close()
+			return nil, err
+		}
+		if signed {
+			return streamed, nil
+		}
+	}
+
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	req = req.Clone(req.Context())
+	if body != nil {
+		req.ContentLength = int64(len(body))
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+	if err := signer.Sign(req, body); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// HMACSigner returns a Signer adding a Signature header in the style of
+// the HTTP Signatures draft, using HMAC-SHA256 with secret.
+//
+// The signature covers the request target, the Date header which is set
+// if missing, a Digest header containing the SHA-256 of the body, and
+// the given additional headers. The body of each request is therefore
+// read into memory before it is sent, including streamed ones.
+func HMACSigner(keyID string, secret []byte, headers ...string) Signer {
+	return HMACSignerFrom(StaticCredentials(Credentials{Username: keyID, Password: string(secret)}), headers...)
+}
+
+// HMACSignerFrom returns a Signer like HMACSigner using the Username and
+// Password of the provider's credentials as the key ID and secret.
+func HMACSignerFrom(provider CredentialProvider, headers ...string) Signer {
+	return &hmacSigner{provider: provider, headers: headers}
+}
+
+type hmacSigner struct {
+	provider CredentialProvider
+	headers  []string
+}
+
+func (signer *hmacSigner) Sign(req *http.Request, body []byte) error {
+	credentials, err := signer.provider.Credentials()
+	if err != nil {
+		return err
+	}
+
+	if req.Header.Get("Date") == "" {
+		req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	}
+	digest := sha256.Sum256(body)
+	req.Header.Set("Digest", "SHA-256="+base64.StdEncoding.EncodeToString(digest[:]))
+
+	names := append([]string{"(request-target)", "date", "digest"}, signer.headers...)
+	lines := make([]string, len(names))
+	for i, name := range names {
+		name = strings.ToLower(name)
+		names[i] = name
+		if name == "(request-target)" {
+			lines[i] = name + ": " + strings.ToLower(req.Method) + " " + req.URL.RequestURI()
+		} else {
+			lines[i] = name + ": " + strings.Join(req.Header.Values(name), ", ")
+		}
+	}
+
+	mac := hmac.New(sha256.New, []byte(credentials.Password))
+	mac.Write([]byte(strings.Join(lines, "\n")))
+	req.Header.Set("Signature", fmt.Sprintf(`keyId=%s,algorithm="hmac-sha256",headers="%s",signature="%s"`,
+		quoteParam(credentials.Username), strings.Join(names, " "), base64.StdEncoding.EncodeToString(mac.Sum(nil))))
+	return nil
+}
+
+// SigV4 is a Signer implementing AWS Signature Version 4.
+//
+// The host, Content-Type and all X-Amz-* headers are signed. The X-Amz-Date
+// header is set to the current time unless the request has one.
+type SigV4 struct {
+	// AccessKeyID and SecretAccessKey are the credentials used to sign,
+	// SessionToken is sent if not empty.
+	AccessKeyID, SecretAccessKey, SessionToken string
+
+	// Credentials overrides the static credentials if set, supplying the
+	// access key ID, secret access key and session token as Username,
+	// Password and Token.
+	Credentials CredentialProvider
+
+	// Region and Service are the scope of the credentials.
+	Region, Service string
+
+	// S3 enables the conventions of S3, which require the payload hash in
+	// the X-Amz-Content-Sha256 header and do not escape paths twice.
+	// Streamed bodies are then sent as UNSIGNED-PAYLOAD rather than being
+	// read into memory to be hashed, which other services do not support.
+	S3 bool
+}
+
+// sigV4UnsignedPayload is the payload hash of requests whose body is not
+// signed.
+const sigV4UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+const sigV4TimeFormat = "20060102T150405Z"
+
+func (signer *SigV4) Sign(req *http.Request, body []byte) error {
+	return signer.sign(req, sha256Hex(body))
+}
+
+func (signer *SigV4) SignStream(req *http.Request) (bool, error) {
+	if !signer.S3 {
+		return false, nil
+	}
+	return true, signer.sign(req, sigV4UnsignedPayload)
+}
+
+// sign signs req, whose body has payloadHash.
+func (signer *SigV4) sign(req *http.Request, payloadHash string) error {
+	credentials := Credentials{
+		Username: signer.AccessKeyID,
+		Password: signer.SecretAccessKey,
+		Token:    signer.SessionToken,
+	}
+	if signer.Credentials != nil {
+		var err error
+		if credentials, err = signer.Credentials.Credentials(); err != nil {
+			return err
+		}
+	}
+
+	amzDate := req.Header.Get("X-Amz-Date")
+	if amzDate == "" {
+		amzDate = time.Now().UTC().Format(sigV4TimeFormat)
+		req.Header.Set("X-Amz-Date", amzDate)
+	}
+	if credentials.Token != "" {
+		req.Header.Set("X-Amz-Security-Token", credentials.Token)
+	}
+	if signer.S3 {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	signedHeaders, canonicalHeaders := sigV4Headers(req)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		signer.canonicalPath(req.URL),
+		sigV4Query(req.URL.Query()),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	date := amzDate[:8]
+	scope := strings.Join([]string{date, signer.Region, signer.Service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := []byte("AWS4" + credentials.Password)
+	for _, part := range []string{date, signer.Region, signer.Service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		credentials.Username, scope, signedHeaders, hex.EncodeToString(hmacSHA256(key, stringToSign))))
+	return nil
+}
+
+func (signer *SigV4) canonicalPath(u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	if signer.S3 {
+		return path
+	}
+
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = sigV4Escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// sigV4Headers returns the names of the signed headers of req and their
+// canonical form.
+func sigV4Headers(req *http.Request) (string, string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	values := map[string]string{"host": host}
+	for name, headerValues := range req.Header {
+		name = strings.ToLower(name)
+		if name == "content-type" || strings.HasPrefix(name, "x-amz-") {
+			trimmed := make([]string, len(headerValues))
+			for i, value := range headerValues {
+				trimmed[i] = strings.Join(strings.Fields(value), " ")
+			}
+			values[name] = strings.Join(trimmed, ",")
+		}
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonical strings.Builder
+	for _, name := range names {
+		canonical.WriteString(name + ":" + values[name] + "\n")
+	}
+	return strings.Join(names, ";"), canonical.String()
+}
+
+func sigV4Query(query url.Values) string {
+	pairs := make([][2]string, 0, len(query))
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, [2]string{sigV4Escape(name), sigV4Escape(value)})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i][0] != pairs[j][0] {
+			return pairs[i][0] < pairs[j][0]
+		}
+		return pairs[i][1] < pairs[j][1]
+	})
+
+	encoded := make([]string, len(pairs))
+	for i, pair := range pairs {
+		encoded[i] = pair[0] + "=" + pair[1]
+	}
+	return strings.Join(encoded, "&")
+}
+
+// sigV4Escape escapes all but the unreserved characters of RFC 3986.
+func sigV4Escape(value string) string {
+	return strings.NewReplacer("+", "%20", "%7E", "~").Replace(url.QueryEscape(value))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
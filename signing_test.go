@@ -0,0 +1,150 @@
+package sling
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The vectors are taken from the AWS Signature Version 4 test suite and
+// documentation, using its example credentials.
+var sigV4Vectors = []struct {
+	name, method, url, contentType, body string
+	region, service                      string
+	authorization                        string
+}{
+	{
+		"get-vanilla", "GET", "https://example.amazonaws.com/", "", "", "us-east-1", "service",
+		"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+	},
+	{
+		"get-vanilla-query-order-key-case", "GET", "https://example.amazonaws.com/?Param2=value2&Param1=value1", "", "", "us-east-1", "service",
+		"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+	},
+	{
+		"post-x-www-form-urlencoded", "POST", "https://example.amazonaws.com/", "application/x-www-form-urlencoded", "Param1=value1", "us-east-1", "service",
+		"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=content-type;host;x-amz-date, Signature=ff11897932ad3f4e8b18135d722051e5ac45fc38421b1da7b9d196a0fe09473a",
+	},
+	{
+		"iam-list-users", "GET", "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", "application/x-www-form-urlencoded; charset=utf-8", "", "us-east-1", "iam",
+		"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, SignedHeaders=content-type;host;x-amz-date, Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7",
+	},
+}
+
+func TestSigV4_MatchesTestVectors(t *testing.T) {
+	for _, vector := range sigV4Vectors {
+		req, _ := http.NewRequest(vector.method, vector.url, nil)
+		req.Header.Set("X-Amz-Date", "20150830T123600Z")
+		if vector.contentType != "" {
+			req.Header.Set("Content-Type", vector.contentType)
+		}
+
+		signer := &SigV4{
+			AccessKeyID:     "AKIDEXAMPLE",
+			SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+			Region:          vector.region,
+			Service:         vector.service,
+		}
+		signer.Sign(req, []byte(vector.body))
+
+		if actual := req.Header.Get("Authorization"); actual != vector.authorization {
+			t.Errorf("%s: Expected Authorization to be\n%s\nbut was\n%s", vector.name, vector.authorization, actual)
+		}
+	}
+}
+
+func TestSigV4_S3RequestsIncludeThePayloadHash(t *testing.T) {
+	req, _ := http.NewRequest("PUT", "https://bucket.s3.amazonaws.com/a%20b", nil)
+	signer := &SigV4{AccessKeyID: "id", SecretAccessKey: "secret", Region: "us-east-1", Service: "s3", S3: true}
+	signer.Sign(req, []byte("data"))
+
+	if actual, expected := req.Header.Get("X-Amz-Content-Sha256"), sha256Hex([]byte("data")); actual != expected {
+		t.Errorf("Expected payload hash to be %s, but was %s", expected, actual)
+	}
+	if authorization := req.Header.Get("Authorization"); !strings.Contains(authorization, "SignedHeaders=host;x-amz-content-sha256;x-amz-date,") {
+		t.Errorf("Expected payload hash to be signed, but got %s", authorization)
+	}
+}
+
+func TestSigV4_StreamedS3RequestsAreNotReadIntoMemory(t *testing.T) {
+	var payloadHash string
+	var body []byte
+	received := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payloadHash = r.Header.Get("X-Amz-Content-Sha256")
+		close(received)
+		body, _ = ioutil.ReadAll(r.Body)
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	reader, writer := io.Pipe()
+	streamed := make(chan bool, 1)
+	go func() {
+		select {
+		case <-received:
+			streamed <- true
+		case <-time.After(time.Second):
+			streamed <- false
+		}
+		writer.Write([]byte("data"))
+		writer.Close()
+	}()
+
+	signer := &SigV4{AccessKeyID: "id", SecretAccessKey: "secret", Region: "us-east-1", Service: "s3", S3: true}
+	client, _ := NewConnectionPool(Config{}).HTTP(server.URL, WithSigner(signer))
+	if err := client.Do(JSONRequest("PUT", "object").RawBody("text/plain", reader, -1)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !<-streamed {
+		t.Error("Expected the request to be sent before its body was complete")
+	}
+	if payloadHash != sigV4UnsignedPayload || string(body) != "data" {
+		t.Errorf("Expected the body to be sent as %s, but got %s with %q", sigV4UnsignedPayload, payloadHash, body)
+	}
+}
+
+func TestSigV4_StreamedRequestsOfOtherServicesAreSignedWithTheirBody(t *testing.T) {
+	req, _ := http.NewRequest("PUT", "https://example.amazonaws.com/", nil)
+	signer := &SigV4{AccessKeyID: "id", SecretAccessKey: "secret", Region: "us-east-1", Service: "service"}
+	if signed, err := signer.SignStream(req); signed || err != nil {
+		t.Errorf("Expected the request to require its body to be signed, but got %v, %v", signed, err)
+	}
+}
+
+func TestSigning_RequestsAreSignedAfterCompression(t *testing.T) {
+	var signature, digest string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature, digest = r.Header.Get("Signature"), r.Header.Get("Digest")
+		body, _ = ioutil.ReadAll(r.Body)
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	pool := NewConnectionPool(Config{RequestCompression: "gzip"})
+	client, _ := pool.HTTP(server.URL, WithSigner(HMACSigner("key", []byte("secret"))))
+	request := JSONRequest("POST", "items?a=b").Header("Date", "Sun, 30 Aug 2015 12:36:00 GMT").Body(map[string]string{"a": "b"})
+	if err := client.Do(request); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	sum := sha256.Sum256(body)
+	if expected := "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:]); digest != expected {
+		t.Errorf("Expected Digest of the compressed body %s, but got %s", expected, digest)
+	}
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("(request-target): post /items?a=b\ndate: Sun, 30 Aug 2015 12:36:00 GMT\ndigest: " + digest))
+	expected := `keyId="key",algorithm="hmac-sha256",headers="(request-target) date digest",signature="` + base64.StdEncoding.EncodeToString(mac.Sum(nil)) + `"`
+	if signature != expected {
+		t.Errorf("Expected Signature to be\n%s\nbut was\n%s", expected, signature)
+	}
+}
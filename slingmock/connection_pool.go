@@ -1,15 +1,19 @@
 package slingmock
 
 import (
+	"context"
+	"errors"
 	"golang.struktur.de/sling"
 	"golang.struktur.de/sling/httpmock"
 	"net/http"
 	"net/url"
+	"sync/atomic"
 	"testing"
 )
 
 type fakeConnectionPool struct {
 	transport *httpmock.Transport
+	closed    int32
 }
 
 // NewConnectionPool returns a connection pool which uses returned mock
@@ -21,16 +25,56 @@ func NewConnectionPool(t *testing.T) (sling.ConnectionPool, *httpmock.Transport)
 	}, transport
 }
 
-func (fake *fakeConnectionPool) HTTP(baseURL string) (sling.HTTP, error) {
+func (fake *fakeConnectionPool) HTTP(baseURL string, options ...sling.HTTPOption) (sling.HTTP, error) {
 	url, _ := url.Parse(baseURL)
 	return &fakeHTTP{
 		Client: &http.Client{
 			Transport: fake.transport,
 		},
-		URL: url,
+		URL:  url,
+		pool: fake,
 	}, nil
 }
 
+// HTTPCluster returns a cluster which sends all requests to the first
+// base URL using the mock Transport.
+func (fake *fakeConnectionPool) HTTPCluster(baseURLs []string, options ...sling.HTTPOption) (sling.Cluster, error) {
+	if len(baseURLs) == 0 {
+		return nil, errors.New("At least one base URL is required")
+	}
+
+	http, _ := fake.HTTP(baseURLs[0])
+	return &fakeCluster{
+		fakeHTTP: http.(*fakeHTTP),
+		baseURLs: baseURLs,
+	}, nil
+}
+
+func (fake *fakeConnectionPool) Stats() sling.Stats {
+	return sling.Stats{}
+}
+
+// Shutdown causes all further requests to fail with sling.ErrPoolClosed.
+func (fake *fakeConnectionPool) Shutdown(ctx context.Context) error {
+	atomic.StoreInt32(&fake.closed, 1)
+	return nil
+}
+
+// Warm does nothing, as the mock Transport has no connections.
+func (fake *fakeConnectionPool) Warm(ctx context.Context) error {
+	if fake.isClosed() {
+		return sling.ErrPoolClosed
+	}
+	return nil
+}
+
+// Update does nothing, as the mock Transport has no configuration.
+func (fake *fakeConnectionPool) Update(config sling.Config) {}
+
+func (fake *fakeConnectionPool) isClosed() bool {
+	return atomic.LoadInt32(&fake.closed) != 0
+}
+
 // NewHTTP creates a HTTP client with it's own ConnectionPool which uses the
 // returned mock Transport to make requests.
 func NewHTTP(t *testing.T, baseURL string) (sling.HTTP, *httpmock.Transport) {
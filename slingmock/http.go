@@ -9,9 +9,14 @@ import (
 type fakeHTTP struct {
 	*http.Client
 	*url.URL
+	pool *fakeConnectionPool
 }
 
 func (fake *fakeHTTP) Do(requestable sling.HTTPRequestable) error {
+	if fake.pool.isClosed() {
+		return sling.ErrPoolClosed
+	}
+
 	req, responder, err := requestable.HTTPRequest(fake.URL)
 	if err != nil {
 		return err
@@ -25,3 +30,19 @@ func (fake *fakeHTTP) Do(requestable sling.HTTPRequestable) error {
 
 	return responder.OnHTTPResponse(res)
 }
+
+type fakeCluster struct {
+	*fakeHTTP
+	baseURLs []string
+}
+
+func (fake *fakeCluster) Endpoints() []sling.EndpointStatus {
+	endpoints := make([]sling.EndpointStatus, len(fake.baseURLs))
+	for i, baseURL := range fake.baseURLs {
+		endpoints[i].URL = baseURL
+		endpoints[i].Healthy = true
+	}
+	return endpoints
+}
+
+func (fake *fakeCluster) Close() {}
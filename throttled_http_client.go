@@ -1,7 +1,9 @@
 package sling
 
 import (
+	"context"
 	"net/http"
+	"time"
 )
 
 type netHTTPClient interface {
@@ -9,31 +11,88 @@ type netHTTPClient interface {
 }
 
 type throttledHTTPClient struct {
-	semaphore
+	*semaphore
 	netHTTPClient
+	limiter *adaptiveLimiter
+
+	// global is the semaphore of a limit shared with other clients, of
+	// which a slot is held along with each slot of the client.
+	global *semaphore
 }
 
 func newThrottledHTTPClient(client netHTTPClient, maxRequests int) netHTTPClient {
 	return &throttledHTTPClient{
-		semaphore:     make(semaphore, maxRequests),
+		semaphore:     newSemaphore(maxRequests),
 		netHTTPClient: client,
 	}
 }
 
-func (throttledClient *throttledHTTPClient) Do(req *http.Request) (*http.Response, error) {
-	throttledClient.Lock()
-	defer throttledClient.Unlock()
-	return throttledClient.netHTTPClient.Do(req)
+// resize sets the concurrency limit to size, or to size within the bounds
+// of the adaptive limit if enabled.
+func (throttledClient *throttledHTTPClient) resize(size int) {
+	if limiter := throttledClient.limiter; limiter != nil {
+		limiter.Lock()
+		defer limiter.Unlock()
+		size = clamp(size, limiter.min, limiter.max)
+	}
+	throttledClient.SetLimit(size)
 }
 
-type nothing struct{}
-type semaphore chan nothing
+func (throttledClient *throttledHTTPClient) stats() HostStats {
+	throttledClient.semaphore.Mutex.Lock()
+	defer throttledClient.semaphore.Mutex.Unlock()
+	return HostStats{
+		Limit:    throttledClient.limit,
+		InFlight: throttledClient.inUse,
+		Waiting:  throttledClient.waitingCount(),
+	}
+}
 
-func (s semaphore) Lock() {
-	n := nothing{}
-	s <- n
+// acquire waits for a slot of the client and of its global limit, which
+// are treated as a single reservation: the time spent waiting for either
+// counts towards the maximum wait and starvation of both.
+func (throttledClient *throttledHTTPClient) acquire(ctx context.Context, priority Priority) error {
+	since := time.Now()
+	if err := throttledClient.lockSince(ctx, priority, since); err != nil {
+		return err
+	}
+	if throttledClient.global != nil {
+		if err := throttledClient.global.lockSince(ctx, priority, since); err != nil {
+			throttledClient.Unlock(priority)
+			return err
+		}
+	}
+	return nil
 }
 
-func (s semaphore) Unlock() {
-	<-s
+// relinquish releases the slots held by acquire.
+func (throttledClient *throttledHTTPClient) relinquish(priority Priority) {
+	if throttledClient.global != nil {
+		throttledClient.global.Unlock(priority)
+	}
+	throttledClient.Unlock(priority)
+}
+
+func (throttledClient *throttledHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	priority := priorityFrom(req)
+	if err := throttledClient.acquire(req.Context(), priority); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	defer throttledClient.relinquish(priority)
+
+	if throttledClient.limiter == nil {
+		return throttledClient.netHTTPClient.Do(req)
+	}
+
+	inFlight, start := throttledClient.InUse(), time.Now()
+	response, err := throttledClient.netHTTPClient.Do(req)
+	throttledClient.limiter.observe(LimitSample{
+		Latency:  time.Since(start),
+		InFlight: inFlight,
+		Failed:   isOverloaded(req, response, err),
+	})
+	return response, err
 }
@@ -2,6 +2,7 @@ package sling
 
 import (
 	"net/http"
+	"net/http/httptest"
 	"sync"
 	"testing"
 	"time"
@@ -61,3 +62,31 @@ func TestThrottledHTTPClient_Do(t *testing.T) {
 		t.Errorf("Expected %p to be the last request executed, but was %p", expectedId, actualId)
 	}
 }
+
+func TestThrottledHTTPClient_ShedsRequestsWaitingTooLong(t *testing.T) {
+	entered, release := make(chan bool), make(chan bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- true
+		<-release
+	}))
+	defer server.Close()
+
+	pool := NewConnectionPool(Config{PoolSize: 1, MaxQueueWait: 10 * time.Millisecond})
+	client, _ := pool.HTTP(server.URL)
+
+	first := make(chan error)
+	go func() {
+		first <- client.Do(JSONRequest("GET", ""))
+	}()
+	<-entered
+
+	if err := client.Do(JSONRequest("GET", "")); err != ErrPoolSaturated {
+		t.Errorf("Expected request to fail with '%v', but was '%v'", ErrPoolSaturated, err)
+	}
+	close(release)
+	<-first
+
+	if shed := pool.Stats().Queue.Shed; shed != 1 {
+		t.Errorf("Expected 1 request to have been shed, but %d were", shed)
+	}
+}
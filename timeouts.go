@@ -0,0 +1,167 @@
+package sling
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Timeouts limits the time spent in each phase of a request, phases are
+// unlimited if their timeout is 0.
+type Timeouts struct {
+	// Dial limits the time spent resolving and connecting to the server.
+	Dial time.Duration
+
+	// TLSHandshake limits the time spent on the TLS handshake.
+	TLSHandshake time.Duration
+
+	// ResponseHeader limits the time between the request having been sent
+	// and the response beginning to arrive.
+	ResponseHeader time.Duration
+
+	// Total limits the time of the whole request, including waiting for a
+	// slot of the pool and processing the response.
+	Total time.Duration
+}
+
+// override returns timeouts with the non-zero fields of overrides applied,
+// negative values disabling the timeout.
+func (timeouts Timeouts) override(overrides *Timeouts) Timeouts {
+	if overrides != nil {
+		for _, field := range []struct{ value, override *time.Duration }{
+			{&timeouts.Dial, &overrides.Dial},
+			{&timeouts.TLSHandshake, &overrides.TLSHandshake},
+			{&timeouts.ResponseHeader, &overrides.ResponseHeader},
+			{&timeouts.Total, &overrides.Total},
+		} {
+			if *field.override != 0 {
+				*field.value = *field.override
+			}
+		}
+	}
+	return timeouts
+}
+
+// Phases of a request named by ErrTimeout.
+const (
+	PhaseDial           = "dial"
+	PhaseTLSHandshake   = "TLS handshake"
+	PhaseResponseHeader = "response header"
+	PhaseTotal          = "request"
+)
+
+// ErrTimeout is returned for requests which exceeded one of their Timeouts.
+// It matches context.DeadlineExceeded when compared using errors.Is.
+type ErrTimeout struct {
+	// Phase is the phase which timed out, one of the Phase constants.
+	Phase string
+
+	// Duration is the timeout of the phase.
+	Duration time.Duration
+}
+
+func (err *ErrTimeout) Error() string {
+	return fmt.Sprintf("Request timed out during %s after %v", err.Phase, err.Duration)
+}
+
+// Timeout returns true, as for timeouts of the net package.
+func (err *ErrTimeout) Timeout() bool {
+	return true
+}
+
+func (err *ErrTimeout) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// timeoutFrom returns the ErrTimeout which cancelled ctx, or err.
+func timeoutFrom(ctx context.Context, err error) error {
+	var timeout *ErrTimeout
+	if ctx.Err() != nil && errors.As(context.Cause(ctx), &timeout) {
+		return timeout
+	}
+	return err
+}
+
+// timeoutHTTPClient cancels requests whose connection or response does not
+// arrive within their phase timeouts.
+type timeoutHTTPClient struct {
+	netHTTPClient
+	timeouts Timeouts
+}
+
+func (client *timeoutHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	timeouts := client.timeouts.override(requestOptionsFrom(req).timeouts)
+	if timeouts.Dial <= 0 && timeouts.TLSHandshake <= 0 && timeouts.ResponseHeader <= 0 {
+		return client.netHTTPClient.Do(req)
+	}
+
+	ctx, cancel := context.WithCancelCause(req.Context())
+	timers := &phaseTimers{cancel: cancel, timers: make(map[string]*time.Timer), finished: make(map[string]bool)}
+	trace := &httptrace.ClientTrace{
+		// Dialing starts with GetConn, as custom dialers do not report
+		// when they start connecting.
+		GetConn: func(string) { timers.start(PhaseDial, timeouts.Dial) },
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				timers.finish(PhaseDial)
+			}
+		},
+		TLSHandshakeStart: func() {
+			timers.finish(PhaseDial)
+			timers.start(PhaseTLSHandshake, timeouts.TLSHandshake)
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) { timers.finish(PhaseTLSHandshake) },
+		// The transport may use an idle connection while a connection
+		// dialed for the request is still being set up.
+		GotConn:              func(httptrace.GotConnInfo) { timers.finish(PhaseDial, PhaseTLSHandshake) },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { timers.start(PhaseResponseHeader, timeouts.ResponseHeader) },
+		GotFirstResponseByte: func() { timers.finish(PhaseResponseHeader) },
+	}
+
+	response, err := client.netHTTPClient.Do(req.WithContext(httptrace.WithClientTrace(ctx, trace)))
+	timers.finish(PhaseDial, PhaseTLSHandshake, PhaseResponseHeader)
+	if err != nil {
+		err = timeoutFrom(ctx, err)
+		cancel(nil)
+		return nil, err
+	}
+	response.Body = &cancellingBody{response.Body, func() { cancel(nil) }}
+	return response, nil
+}
+
+// phaseTimers cancels a request once any of its running timers expires.
+type phaseTimers struct {
+	sync.Mutex
+	cancel   context.CancelCauseFunc
+	timers   map[string]*time.Timer
+	finished map[string]bool
+}
+
+// start starts the timer of phase unless it is running or has finished.
+func (timers *phaseTimers) start(phase string, timeout time.Duration) {
+	timers.Lock()
+	defer timers.Unlock()
+	if timeout <= 0 || timers.finished[phase] || timers.timers[phase] != nil {
+		return
+	}
+	timers.timers[phase] = time.AfterFunc(timeout, func() {
+		timers.cancel(&ErrTimeout{Phase: phase, Duration: timeout})
+	})
+}
+
+// finish stops the timers of phases, which are not started again.
+func (timers *phaseTimers) finish(phases ...string) {
+	timers.Lock()
+	defer timers.Unlock()
+	for _, phase := range phases {
+		timers.finished[phase] = true
+		if timer := timers.timers[phase]; timer != nil {
+			timer.Stop()
+		}
+	}
+}
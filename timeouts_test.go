@@ -0,0 +1,104 @@
+package sling_test
+
+import (
+	"context"
+	"errors"
+	"golang.struktur.de/sling"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// assertTimeout fails t unless err is a timeout of phase.
+func assertTimeout(t *testing.T, err error, phase string) {
+	var timeout *sling.ErrTimeout
+	if !errors.As(err, &timeout) || timeout.Phase != phase {
+		t.Errorf("Expected a timeout during %s, but got %v", phase, err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected %v to match %v", err, context.DeadlineExceeded)
+	}
+}
+
+// newStallingServer starts a server which sends its response header and
+// then stalls for delay before sending its body.
+func newStallingServer(headerDelay, bodyDelay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(headerDelay)
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		time.Sleep(bodyDelay)
+		w.Write([]byte("{}"))
+	}))
+}
+
+func TestTimeouts_ResponseHeaderTimeoutExpires(t *testing.T) {
+	server := newStallingServer(200*time.Millisecond, 0)
+	defer server.Close()
+
+	client, _ := sling.NewConnectionPool(sling.Config{
+		Timeouts: sling.Timeouts{ResponseHeader: 20 * time.Millisecond},
+	}).HTTP(server.URL)
+
+	assertTimeout(t, client.Do(sling.JSONRequest("GET", "")), sling.PhaseResponseHeader)
+}
+
+func TestTimeouts_RequestsMayOverrideThePoolsTimeouts(t *testing.T) {
+	server := newStallingServer(50*time.Millisecond, 0)
+	defer server.Close()
+
+	client, _ := sling.NewConnectionPool(sling.Config{
+		Timeouts: sling.Timeouts{ResponseHeader: 10 * time.Millisecond},
+	}).HTTP(server.URL)
+
+	if err := client.Do(sling.JSONRequest("GET", "").Timeouts(sling.Timeouts{ResponseHeader: -1})); err != nil {
+		t.Errorf("Unexpected error with the timeout disabled: %v", err)
+	}
+	if err := client.Do(sling.JSONRequest("GET", "").Timeouts(sling.Timeouts{ResponseHeader: time.Second})); err != nil {
+		t.Errorf("Unexpected error with a longer timeout: %v", err)
+	}
+}
+
+func TestTimeouts_TotalTimeoutIncludesTheResponseBody(t *testing.T) {
+	server := newStallingServer(0, 200*time.Millisecond)
+	defer server.Close()
+
+	client, _ := sling.NewConnectionPool(sling.Config{}).HTTP(server.URL)
+	request := sling.JSONRequest("GET", "").Success(&struct{}{}).Timeouts(sling.Timeouts{Total: 30 * time.Millisecond})
+
+	assertTimeout(t, client.Do(request), sling.PhaseTotal)
+}
+
+func TestTimeouts_DialTimeoutExpires(t *testing.T) {
+	client, _ := sling.NewConnectionPool(sling.Config{
+		Timeouts: sling.Timeouts{Dial: 20 * time.Millisecond},
+		Dialer: func(ctx context.Context, network, address string) (net.Conn, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}).HTTP("http://backend.invalid")
+
+	assertTimeout(t, client.Do(sling.JSONRequest("GET", "")), sling.PhaseDial)
+}
+
+func TestTimeouts_TLSHandshakeTimeoutExpires(t *testing.T) {
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	client, _ := sling.NewConnectionPool(sling.Config{
+		Timeouts: sling.Timeouts{TLSHandshake: 20 * time.Millisecond},
+	}).HTTP("https://" + listener.Addr().String())
+
+	assertTimeout(t, client.Do(sling.JSONRequest("GET", "")), sling.PhaseTLSHandshake)
+}
@@ -0,0 +1,243 @@
+package sling
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// TraceResult contains the connection diagnostics of a single request.
+//
+// Durations are zero for phases which did not occur, for example DNS and
+// Connect are zero when a connection was reused.
+type TraceResult struct {
+	// GotConn is true if a connection was obtained for the request.
+	GotConn bool
+
+	// Reused is true if the connection had been used for a previous request.
+	Reused bool
+
+	// WasIdle is true if the connection was obtained from the idle pool,
+	// IdleTime is then how long it had been idle.
+	WasIdle  bool
+	IdleTime time.Duration
+
+	// DNS, Connect and TLSHandshake are the durations of host resolution,
+	// dialing and the TLS handshake respectively.
+	DNS, Connect, TLSHandshake time.Duration
+
+	// TimeToFirstByte is the duration from requesting a connection until
+	// the first byte of the response was received.
+	TimeToFirstByte time.Duration
+}
+
+// ConnectionStats aggregates the TraceResults of all traced requests
+// made to a base URL.
+type ConnectionStats struct {
+	// Requests is the number of traced requests which obtained a
+	// connection, Reused the number of those which reused a connection.
+	Requests, Reused int64
+
+	// DNS, Connect, TLSHandshake and TimeToFirstByte are the total
+	// durations of the respective phases over all traced requests.
+	DNS, Connect, TLSHandshake, TimeToFirstByte time.Duration
+}
+
+// ReuseRatio returns the fraction of traced requests which reused
+// a connection.
+func (stats ConnectionStats) ReuseRatio() float64 {
+	if stats.Requests == 0 {
+		return 0
+	}
+	return float64(stats.Reused) / float64(stats.Requests)
+}
+
+func (stats *ConnectionStats) add(result *TraceResult) {
+	if !result.GotConn {
+		return
+	}
+	stats.Requests++
+	if result.Reused {
+		stats.Reused++
+	}
+	stats.DNS += result.DNS
+	stats.Connect += result.Connect
+	stats.TLSHandshake += result.TLSHandshake
+	stats.TimeToFirstByte += result.TimeToFirstByte
+}
+
+type connectionStats struct {
+	sync.Mutex
+	byBaseURL map[string]*baseURLStats
+}
+
+// baseURLStats are the stats of a base URL along with the number of clients
+// and endpoints using it.
+type baseURLStats struct {
+	ConnectionStats
+	refs int
+}
+
+func newConnectionStats() *connectionStats {
+	return &connectionStats{byBaseURL: make(map[string]*baseURLStats)}
+}
+
+// register returns the stats of baseURL, which must be forgotten once it is
+// no longer used.
+func (stats *connectionStats) register(baseURL string) *baseURLStats {
+	stats.Lock()
+	defer stats.Unlock()
+	if stats.byBaseURL[baseURL] == nil {
+		stats.byBaseURL[baseURL] = new(baseURLStats)
+	}
+	stats.byBaseURL[baseURL].refs++
+	return stats.byBaseURL[baseURL]
+}
+
+// forget removes the stats of baseURL once it is no longer used, results of
+// requests still in flight are dropped.
+func (stats *connectionStats) forget(baseURL string) {
+	stats.Lock()
+	defer stats.Unlock()
+	if entry := stats.byBaseURL[baseURL]; entry != nil {
+		if entry.refs--; entry.refs <= 0 {
+			delete(stats.byBaseURL, baseURL)
+		}
+	}
+}
+
+func (stats *connectionStats) add(entry *baseURLStats, result *TraceResult) {
+	stats.Lock()
+	defer stats.Unlock()
+	entry.add(result)
+}
+
+func (stats *connectionStats) snapshot() map[string]ConnectionStats {
+	stats.Lock()
+	defer stats.Unlock()
+	snapshot := make(map[string]ConnectionStats, len(stats.byBaseURL))
+	for baseURL, entry := range stats.byBaseURL {
+		if entry.Requests > 0 {
+			snapshot[baseURL] = entry.ConnectionStats
+		}
+	}
+	return snapshot
+}
+
+// tracingHTTPClient records the TraceResult of requests to a base URL if
+// tracing is enabled for the pool or the request itself.
+type tracingHTTPClient struct {
+	netHTTPClient
+	baseURL  string
+	enabled  bool
+	callback func(baseURL string, result TraceResult)
+	stats    *connectionStats
+	entry    *baseURLStats
+}
+
+func newTracingHTTPClient(client netHTTPClient, baseURL string, enabled bool, callback func(string, TraceResult), stats *connectionStats) netHTTPClient {
+	return &tracingHTTPClient{
+		netHTTPClient: client,
+		baseURL:       baseURL,
+		enabled:       enabled || callback != nil,
+		callback:      callback,
+		stats:         stats,
+		entry:         stats.register(baseURL),
+	}
+}
+
+func (client *tracingHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	target := requestOptionsFrom(req).trace
+	if !client.enabled && target == nil {
+		return client.netHTTPClient.Do(req)
+	}
+
+	tracer := new(tracer)
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), tracer.clientTrace()))
+	response, err := client.netHTTPClient.Do(req)
+
+	result := tracer.result()
+	if target != nil {
+		*target = result
+	}
+	if client.enabled {
+		client.stats.add(client.entry, &result)
+		if client.callback != nil {
+			client.callback(client.baseURL, result)
+		}
+	}
+	return response, err
+}
+
+// tracer collects a TraceResult from httptrace hooks, which may be
+// called concurrently.
+type tracer struct {
+	sync.Mutex
+	TraceResult
+	getConn, dnsStart, connectStart, tlsStart time.Time
+}
+
+func (tracer *tracer) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GetConn: func(string) {
+			tracer.Lock()
+			defer tracer.Unlock()
+			tracer.getConn = time.Now()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			tracer.Lock()
+			defer tracer.Unlock()
+			tracer.GotConn = true
+			tracer.Reused = info.Reused
+			tracer.WasIdle = info.WasIdle
+			tracer.IdleTime = info.IdleTime
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			tracer.Lock()
+			defer tracer.Unlock()
+			tracer.dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			tracer.Lock()
+			defer tracer.Unlock()
+			tracer.DNS = time.Since(tracer.dnsStart)
+		},
+		ConnectStart: func(string, string) {
+			tracer.Lock()
+			defer tracer.Unlock()
+			if tracer.connectStart.IsZero() {
+				tracer.connectStart = time.Now()
+			}
+		},
+		ConnectDone: func(_, _ string, err error) {
+			tracer.Lock()
+			defer tracer.Unlock()
+			if err == nil {
+				tracer.Connect = time.Since(tracer.connectStart)
+			}
+		},
+		TLSHandshakeStart: func() {
+			tracer.Lock()
+			defer tracer.Unlock()
+			tracer.tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			tracer.Lock()
+			defer tracer.Unlock()
+			tracer.TLSHandshake = time.Since(tracer.tlsStart)
+		},
+		GotFirstResponseByte: func() {
+			tracer.Lock()
+			defer tracer.Unlock()
+			tracer.TimeToFirstByte = time.Since(tracer.getConn)
+		},
+	}
+}
+
+func (tracer *tracer) result() TraceResult {
+	tracer.Lock()
+	defer tracer.Unlock()
+	return tracer.TraceResult
+}
@@ -0,0 +1,97 @@
+package sling_test
+
+import (
+	"golang.struktur.de/sling"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTracingTestServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}\n"))
+	}))
+}
+
+func TestTracing_RequestResultReportsConnectionReuse(t *testing.T) {
+	server := newTracingTestServer()
+	defer server.Close()
+
+	client, err := sling.NewHTTP(server.URL, sling.Config{PoolSize: 1})
+	if err != nil {
+		t.Fatalf("Unexpected error '%v' creating client", err)
+	}
+
+	var first, second sling.TraceResult
+	if err := client.Do(sling.JSONRequest("GET", "").Trace(&first)); err != nil {
+		t.Fatalf("Unexpected error '%v' making request", err)
+	}
+	if err := client.Do(sling.JSONRequest("GET", "").Trace(&second)); err != nil {
+		t.Fatalf("Unexpected error '%v' making request", err)
+	}
+
+	if !first.GotConn || first.Reused || first.Connect == 0 {
+		t.Errorf("Expected first request to have used a new connection, but result was %+v", first)
+	}
+
+	if !second.GotConn || !second.Reused || !second.WasIdle || second.Connect != 0 {
+		t.Errorf("Expected second request to have reused an idle connection, but result was %+v", second)
+	}
+
+	if first.TimeToFirstByte == 0 || second.TimeToFirstByte == 0 {
+		t.Errorf("Expected time to first byte to have been recorded, but results were %+v and %+v", first, second)
+	}
+}
+
+func TestTracing_MultipartRequestsMayBeTraced(t *testing.T) {
+	server := newTracingTestServer()
+	defer server.Close()
+
+	client, _ := sling.NewHTTP(server.URL, sling.Config{})
+	var result sling.TraceResult
+	if err := client.Do(sling.MultipartRequest("POST", "").Field("a", "b").Trace(&result)); err != nil {
+		t.Fatalf("Unexpected error '%v' making request", err)
+	}
+	if !result.GotConn || result.TimeToFirstByte == 0 {
+		t.Errorf("Expected the request to have been traced, but result was %+v", result)
+	}
+}
+
+func TestTracing_PoolAggregatesResultsPerBaseURL(t *testing.T) {
+	server := newTracingTestServer()
+	defer server.Close()
+
+	var callbacks int
+	pool := sling.NewConnectionPool(sling.Config{
+		PoolSize: 1,
+		OnTrace: func(baseURL string, result sling.TraceResult) {
+			if baseURL != server.URL+"/" {
+				t.Errorf("Expected callback for base URL '%s', but was '%s'", server.URL+"/", baseURL)
+			}
+			callbacks++
+		},
+	})
+	client, err := pool.HTTP(server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error '%v' creating client", err)
+	}
+
+	for i := 0; i < 4; i++ {
+		if err := client.Do(sling.JSONRequest("GET", "")); err != nil {
+			t.Fatalf("Unexpected error '%v' making request", err)
+		}
+	}
+
+	if callbacks != 4 {
+		t.Errorf("Expected trace callback to have been called %d times, but was %d", 4, callbacks)
+	}
+
+	stats, ok := pool.Stats().Connections[server.URL+"/"]
+	if !ok {
+		t.Fatalf("No connection stats recorded for '%s'", server.URL)
+	}
+
+	if stats.Requests != 4 || stats.Reused != 3 || stats.ReuseRatio() != 0.75 {
+		t.Errorf("Expected 3 of 4 requests to have reused a connection, but stats were %+v", stats)
+	}
+}
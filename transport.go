@@ -0,0 +1,111 @@
+package sling
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// swappableTransport is a http.RoundTripper whose underlying transport may
+// be replaced while requests are being made.
+type swappableTransport struct {
+	current atomic.Value
+
+	// retiredMutex guards retired, the previous transports which still
+	// had requests in flight when they were last checked.
+	retiredMutex sync.Mutex
+	retired      []*transportGeneration
+}
+
+// transportGeneration is a transport along with the number of its requests
+// in flight, whose connections are closed once it has been retired and
+// its last request has completed.
+type transportGeneration struct {
+	*http.Transport
+	inFlight int64
+	retired  int32
+}
+
+func newSwappableTransport(transport *http.Transport) *swappableTransport {
+	swappable := &swappableTransport{}
+	swappable.current.Store(&transportGeneration{Transport: transport})
+	return swappable
+}
+
+func (swappable *swappableTransport) generation() *transportGeneration {
+	return swappable.current.Load().(*transportGeneration)
+}
+
+func (swappable *swappableTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	generation := swappable.generation()
+	atomic.AddInt64(&generation.inFlight, 1)
+
+	response, err := generation.RoundTrip(req)
+	if err != nil {
+		generation.release()
+		return nil, err
+	}
+	response.Body = &releasingBody{ReadCloser: response.Body, release: generation.release}
+	return response, nil
+}
+
+// release marks a request as completed, closing the connections of the
+// generation if it was its last and the generation has been retired.
+func (generation *transportGeneration) release() {
+	if atomic.AddInt64(&generation.inFlight, -1) == 0 && atomic.LoadInt32(&generation.retired) != 0 {
+		// This also makes the transport close connections which only
+		// become idle after the call.
+		generation.CloseIdleConnections()
+	}
+}
+
+// CloseIdleConnections closes the idle connections of the current transport
+// and of previous transports.
+func (swappable *swappableTransport) CloseIdleConnections() {
+	swappable.generation().CloseIdleConnections()
+	swappable.closeRetired()
+}
+
+// swap replaces the current transport with transport. Requests in flight
+// complete using the previous transport, whose idle connections are closed
+// immediately and the remainder once its last request has completed.
+func (swappable *swappableTransport) swap(transport *http.Transport) {
+	previous := swappable.generation()
+	swappable.current.Store(&transportGeneration{Transport: transport})
+	atomic.StoreInt32(&previous.retired, 1)
+
+	swappable.retiredMutex.Lock()
+	swappable.retired = append(swappable.retired, previous)
+	swappable.retiredMutex.Unlock()
+	swappable.closeRetired()
+}
+
+// closeRetired closes the idle connections of retired transports, keeping
+// only those with requests in flight.
+func (swappable *swappableTransport) closeRetired() {
+	swappable.retiredMutex.Lock()
+	defer swappable.retiredMutex.Unlock()
+
+	remaining := swappable.retired[:0]
+	for _, generation := range swappable.retired {
+		generation.CloseIdleConnections()
+		if atomic.LoadInt64(&generation.inFlight) > 0 {
+			remaining = append(remaining, generation)
+		}
+	}
+	swappable.retired = remaining
+}
+
+// releasingBody releases its request once closed.
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (body *releasingBody) Close() error {
+	err := body.ReadCloser.Close()
+	body.once.Do(body.release)
+	return err
+}
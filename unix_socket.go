@@ -0,0 +1,97 @@
+package sling
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// UnixSocketScheme is the scheme of base URLs whose requests are sent over
+// a Unix domain socket, such as http+unix:///var/run/docker.sock:/v1.41/.
+//
+// The path of the socket is separated from the path of the base URL by a
+// colon. Requests are resolved against the base URL as usual, and sent
+// with a host name derived from the socket's path ending in
+// unixSocketDomain.
+const UnixSocketScheme = "http+unix"
+
+// unixSocketDomain is the domain of the host names given to Unix domain
+// sockets, which as a subdomain of the reserved .invalid top level domain
+// can never be the name of a real host.
+const unixSocketDomain = ".sock.invalid"
+
+// unixSockets maps the host names given to Unix domain sockets to their
+// paths.
+type unixSockets struct {
+	sync.Mutex
+	paths map[string]string
+}
+
+// baseURL returns the http base URL for a http+unix base URL, registering
+// a host name for its socket. Other base URLs are returned unchanged.
+func (sockets *unixSockets) baseURL(baseURL string) (string, error) {
+	prefix := UnixSocketScheme + "://"
+	if !strings.HasPrefix(baseURL, prefix) {
+		return baseURL, nil
+	}
+
+	path := strings.TrimPrefix(baseURL, prefix)
+	separator := strings.Index(path, ":")
+	if !strings.HasPrefix(path, "/") || separator < 0 {
+		return "", errors.New("Unix socket base URLs require a socket path followed by a colon")
+	}
+	socket, path := path[:separator], path[separator+1:]
+
+	return "http://" + sockets.host(socket) + "/" + strings.TrimLeft(path, "/"), nil
+}
+
+// host returns the host name of socket, which is unique within the pool.
+func (sockets *unixSockets) host(socket string) string {
+	sockets.Lock()
+	defer sockets.Unlock()
+
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' {
+			return r
+		}
+		return '_'
+	}, strings.Trim(socket, "/"))
+
+	host := name + unixSocketDomain
+	for suffix := 2; sockets.paths[host] != "" && sockets.paths[host] != socket; suffix++ {
+		host = name + "-" + strconv.Itoa(suffix) + unixSocketDomain
+	}
+	sockets.paths[host] = socket
+	return host
+}
+
+// path returns the socket path of the host of address, if any.
+func (sockets *unixSockets) path(address string) (string, bool) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	if !strings.HasSuffix(host, unixSocketDomain) {
+		return "", false
+	}
+
+	sockets.Lock()
+	defer sockets.Unlock()
+	path, ok := sockets.paths[host]
+	return path, ok
+}
+
+// dial connects to address using the pool's Dialer, or to the Unix domain
+// socket of its host using the default dialer.
+func (pool *pool) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	if path, ok := pool.unixSockets.path(address); ok {
+		return pool.dialer.DialContext(ctx, "unix", path)
+	}
+	if pool.Dialer != nil {
+		return pool.Dialer(ctx, network, address)
+	}
+	return pool.dialer.DialContext(ctx, network, address)
+}
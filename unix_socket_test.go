@@ -0,0 +1,113 @@
+package sling
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// recordPath is a handler recording the path and host of requests.
+func recordPath(path, host *string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*path, *host = r.URL.Path, r.Host
+		w.Write([]byte("{}"))
+	})
+}
+
+func TestUnixSocket_RequestsAreSentOverTheSocket(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "sling")
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "api.sock")
+
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("Failed to listen on %s: %v", socket, err)
+	}
+	var path, host string
+	server := &http.Server{Handler: recordPath(&path, &host)}
+	go server.Serve(listener)
+	defer server.Close()
+
+	client, err := NewConnectionPool(Config{}).HTTP("http+unix://" + socket + ":/v1/")
+	if err != nil {
+		t.Fatalf("Unexpected error creating client: %v", err)
+	}
+	if err := client.Do(JSONRequest("GET", "items")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if path != "/v1/items" {
+		t.Errorf("Expected path to be %s, but was %s", "/v1/items", path)
+	}
+	if host == "" {
+		t.Error("Expected requests to have a host name")
+	}
+}
+
+func TestUnixSocket_BaseURLsRequireASocketPath(t *testing.T) {
+	pool := NewConnectionPool(Config{})
+	if _, err := pool.HTTP("http+unix://localhost/v1/"); err == nil {
+		t.Error("Expected base URL without a socket path to fail")
+	}
+	if _, err := pool.HTTPCluster([]string{"http+unix:///var/run/api.sock"}); err == nil {
+		t.Error("Expected base URL without a colon after the socket path to fail")
+	}
+}
+
+func TestUnixSocket_HostsAreUniquePerSocket(t *testing.T) {
+	sockets := &unixSockets{paths: make(map[string]string)}
+	first, second := sockets.host("/run/a_b.sock"), sockets.host("/run/a/b.sock")
+
+	if first == second {
+		t.Errorf("Expected different hosts for different sockets, but both were %s", first)
+	}
+	if again := sockets.host("/run/a/b.sock"); again != second {
+		t.Errorf("Expected the host of a socket to be %s, but was %s", second, again)
+	}
+}
+
+func TestUnixSocket_HostsCannotBeRealHostNames(t *testing.T) {
+	sockets := &unixSockets{paths: make(map[string]string)}
+	host := sockets.host("/run/app.sock")
+
+	if !strings.HasSuffix(host, ".invalid") {
+		t.Errorf("Expected the host of a socket to be in the .invalid domain, but was %s", host)
+	}
+	if _, ok := sockets.path(host + ":80"); !ok {
+		t.Errorf("Expected %s to be dialed over the socket", host)
+	}
+	if _, ok := sockets.path(strings.TrimSuffix(host, ".sock.invalid") + ":80"); ok {
+		t.Error("Expected hosts outside of the socket domain never to be dialed over a socket")
+	}
+}
+
+func TestDialer_IsUsedForConnections(t *testing.T) {
+	var path, host string
+	server := httptest.NewServer(recordPath(&path, &host))
+	defer server.Close()
+
+	var dialed string
+	pool := NewConnectionPool(Config{
+		Dialer: func(ctx context.Context, network, address string) (net.Conn, error) {
+			dialed = address
+			return net.Dial(network, server.Listener.Addr().String())
+		},
+	})
+	client, _ := pool.HTTP("http://logical.invalid/api")
+
+	if err := client.Do(JSONRequest("GET", "items")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if dialed != "logical.invalid:80" {
+		t.Errorf("Expected dialer to be called for %s, but got %s", "logical.invalid:80", dialed)
+	}
+	if host != "logical.invalid" || path != "/api/items" {
+		t.Errorf("Expected request for %s, but got %s%s", "logical.invalid/api/items", host, path)
+	}
+}
@@ -0,0 +1,186 @@
+package sling
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sync"
+)
+
+// origin returns the scheme and host of baseURL, which identify the
+// connections its requests use.
+func origin(baseURL *url.URL) string {
+	return baseURL.Scheme + "://" + baseURL.Host
+}
+
+// originRef is an origin to be warmed along with the limits which the
+// requests of the clients and endpoints using it count against.
+type originRef struct {
+	*url.URL
+
+	// limits counts the references to the origin by their limit.
+	limits map[*throttledHTTPClient]int
+}
+
+// addOrigin records the origin of baseURL to be warmed against limit, the
+// caller must hold the endpointsMutex and release the origin once it is no
+// longer used.
+func (pool *pool) addOrigin(baseURL *url.URL, limit *throttledHTTPClient) {
+	key := origin(baseURL)
+	if pool.origins[key] == nil {
+		pool.origins[key] = &originRef{
+			URL:    &url.URL{Scheme: baseURL.Scheme, Host: baseURL.Host, Path: "/"},
+			limits: make(map[*throttledHTTPClient]int),
+		}
+	}
+	pool.origins[key].limits[limit]++
+}
+
+// releaseOrigin releases a reference to the origin of baseURL by limit,
+// which is no longer warmed once it is unused. The caller must hold the
+// endpointsMutex.
+func (pool *pool) releaseOrigin(baseURL *url.URL, limit *throttledHTTPClient) {
+	key := origin(baseURL)
+	if origin := pool.origins[key]; origin != nil {
+		if origin.limits[limit]--; origin.limits[limit] <= 0 {
+			delete(origin.limits, limit)
+		}
+		if len(origin.limits) == 0 {
+			delete(pool.origins, key)
+		}
+	}
+}
+
+func (pool *pool) Warm(ctx context.Context) error {
+	if err := pool.lifecycle.acquire(); err != nil {
+		return err
+	}
+	defer pool.lifecycle.release()
+
+	pool.endpointsMutex.Lock()
+	targets := make(map[*url.URL][]warmTarget, len(pool.origins))
+	for _, origin := range pool.origins {
+		targets[origin.URL] = pool.warmTargets(origin)
+	}
+	pool.endpointsMutex.Unlock()
+
+	// Origins are warmed one at a time, as requests to several origins
+	// sharing a limit could otherwise each hold some of its slots while
+	// waiting for the others.
+	var result error
+	for baseURL, targets := range targets {
+		if err := pool.warm(ctx, baseURL, targets); err != nil && result == nil {
+			result = err
+		}
+	}
+	return result
+}
+
+// warmTarget is a number of connections to open to an origin, along with
+// the limit which the requests opening them count against.
+type warmTarget struct {
+	connections int
+	limit       *throttledHTTPClient
+}
+
+// warmTargets returns a target for each limit of origin, whose connections
+// are shared by all of them. The caller must hold the endpointsMutex.
+func (pool *pool) warmTargets(origin *originRef) []warmTarget {
+	remaining := pool.maxIdleConnsPerHost()
+	if pool.HTTP2.multiplexed() {
+		remaining = 1
+	}
+
+	poolSize := pool.poolSize
+	if pool.PerHost {
+		poolSize = pool.hostPoolSize(origin.Host)
+	}
+
+	var targets []warmTarget
+	for limit := range origin.limits {
+		connections := min(remaining, poolSize, limit.capacity(PriorityLow))
+		if global := limit.global; global != nil {
+			connections = min(connections, global.capacity(PriorityLow))
+		}
+		if connections > 0 {
+			targets = append(targets, warmTarget{connections: connections, limit: limit})
+			remaining -= connections
+		}
+	}
+	return targets
+}
+
+// warm opens the connections of targets to the origin of baseURL by sending
+// as many concurrent HEAD requests, each of which holds on to its connection
+// until all requests have obtained one so that none can be reused.
+func (pool *pool) warm(ctx context.Context, baseURL *url.URL, targets []warmTarget) error {
+	connections := 0
+	for _, target := range targets {
+		connections += target.connections
+	}
+	var obtained sync.WaitGroup
+	obtained.Add(connections)
+	held := make(chan nothing)
+
+	errs := make(chan error, connections)
+	for _, target := range targets {
+		for i := 0; i < target.connections; i++ {
+			go func() {
+				var once sync.Once
+				done := func() { once.Do(obtained.Done) }
+				defer done()
+
+				trace := &httptrace.ClientTrace{
+					GotConn: func(httptrace.GotConnInfo) {
+						done()
+						select {
+						case <-held:
+						case <-ctx.Done():
+						}
+					},
+				}
+				errs <- pool.head(httptrace.WithClientTrace(ctx, trace), baseURL, target.limit)
+			}()
+		}
+	}
+
+	obtained.Wait()
+	close(held)
+	return firstError(errs, connections)
+}
+
+// head sends a HEAD request to baseURL holding a slot of limit, without
+// following redirects.
+func (pool *pool) head(ctx context.Context, baseURL *url.URL, limit *throttledHTTPClient) error {
+	if err := limit.acquire(ctx, PriorityLow); err != nil {
+		return err
+	}
+	defer limit.relinquish(PriorityLow)
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodHead, baseURL.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	// The status of the response is irrelevant, as even errors and
+	// redirects leave the connection open for further requests.
+	response, err := pool.transport.RoundTrip(request)
+	if err != nil {
+		return err
+	}
+	closeResponse(response, pool.MaxDrainSize)
+	return nil
+}
+
+// firstError receives count errors from errs, returning the first which is
+// not nil.
+func firstError(errs <-chan error, count int) error {
+	var result error
+	for i := 0; i < count; i++ {
+		if err := <-errs; err != nil && result == nil {
+			result = err
+		}
+	}
+	return result
+}
@@ -0,0 +1,201 @@
+package sling_test
+
+import (
+	"context"
+	"golang.struktur.de/sling"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newConnectionCountingServer starts a server which counts the connections
+// opened and closed by its clients.
+func newConnectionCountingServer() (server *httptest.Server, opened, closed *int32) {
+	opened, closed = new(int32), new(int32)
+	server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		switch state {
+		case http.StateNew:
+			atomic.AddInt32(opened, 1)
+		case http.StateClosed:
+			atomic.AddInt32(closed, 1)
+		}
+	}
+	server.Start()
+	return server, opened, closed
+}
+
+func TestWarm_OpensPoolSizeConnections(t *testing.T) {
+	server, opened, _ := newConnectionCountingServer()
+	defer server.Close()
+
+	pool := sling.NewConnectionPool(sling.Config{PoolSize: 3})
+	client, _ := pool.HTTP(server.URL + "/api")
+	if err := pool.Warm(context.Background()); err != nil {
+		t.Fatalf("Unexpected error warming pool: %v", err)
+	}
+	if count := atomic.LoadInt32(opened); count != 3 {
+		t.Errorf("Expected %d connections to have been opened, but %d were", 3, count)
+	}
+
+	if err := client.Do(sling.JSONRequest("GET", "")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if count := atomic.LoadInt32(opened); count != 3 {
+		t.Errorf("Expected requests to reuse the warm connections, but %d were opened", count)
+	}
+}
+
+func TestWarm_OpensConnectionsToClusterEndpoints(t *testing.T) {
+	first, firstOpened, _ := newConnectionCountingServer()
+	defer first.Close()
+	second, secondOpened, _ := newConnectionCountingServer()
+	defer second.Close()
+
+	pool := sling.NewConnectionPool(sling.Config{PoolSize: 2})
+	pool.HTTPCluster([]string{first.URL, second.URL})
+	if err := pool.Warm(context.Background()); err != nil {
+		t.Fatalf("Unexpected error warming pool: %v", err)
+	}
+	for _, count := range []int32{atomic.LoadInt32(firstOpened), atomic.LoadInt32(secondOpened)} {
+		if count != 2 {
+			t.Errorf("Expected %d connections to each endpoint, but got %d", 2, count)
+		}
+	}
+}
+
+func TestWarm_ConnectionsAreLimitedByMaxIdleConnsPerHost(t *testing.T) {
+	server, opened, _ := newConnectionCountingServer()
+	defer server.Close()
+
+	pool := sling.NewConnectionPool(sling.Config{PoolSize: 4, MaxIdleConnsPerHost: 1})
+	pool.HTTP(server.URL)
+	if err := pool.Warm(context.Background()); err != nil {
+		t.Fatalf("Unexpected error warming pool: %v", err)
+	}
+	if count := atomic.LoadInt32(opened); count != 1 {
+		t.Errorf("Expected %d connection to have been opened, but %d were", 1, count)
+	}
+}
+
+func TestWarm_RedirectsAreNotFollowed(t *testing.T) {
+	target, opened, _ := newConnectionCountingServer()
+	defer target.Close()
+	server := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusFound))
+	defer server.Close()
+
+	pool := sling.NewConnectionPool(sling.Config{PoolSize: 2})
+	pool.HTTP(server.URL)
+	if err := pool.Warm(context.Background()); err != nil {
+		t.Fatalf("Unexpected error warming pool: %v", err)
+	}
+	if count := atomic.LoadInt32(opened); count != 0 {
+		t.Errorf("Expected no connections to the redirect target, but %d were opened", count)
+	}
+}
+
+func TestWarm_ConnectionsHoldSlotsOfTheirHost(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+
+	pool := sling.NewConnectionPool(sling.Config{PoolSize: 2, PerHost: true})
+	pool.HTTP(server.URL)
+	warmed := make(chan error, 1)
+	go func() {
+		warmed <- pool.Warm(context.Background())
+	}()
+
+	host := server.Listener.Addr().String()
+	deadline := time.Now().Add(time.Second)
+	for pool.Stats().Hosts[host].InFlight != 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if stats := pool.Stats().Hosts[host]; stats.InFlight != 2 {
+		t.Errorf("Expected warming to hold %d slots of the host, but got %+v", 2, stats)
+	}
+	close(release)
+	if err := <-warmed; err != nil {
+		t.Fatalf("Unexpected error warming pool: %v", err)
+	}
+}
+
+func TestWarm_ConnectionsHoldSlotsOfTheirClusterEndpoint(t *testing.T) {
+	opened, release := new(int32), make(chan struct{})
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			<-release
+		}
+		w.Write([]byte("{}"))
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(opened, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	pool := sling.NewConnectionPool(sling.Config{PoolSize: 1})
+	cluster, _ := pool.HTTPCluster([]string{server.URL})
+	defer cluster.Close()
+	requested := make(chan error, 1)
+	go func() {
+		requested <- cluster.Do(sling.JSONRequest("GET", ""))
+	}()
+	deadline := time.Now().Add(time.Second)
+	for cluster.Endpoints()[0].InFlight != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	warmed := make(chan error, 1)
+	go func() {
+		warmed <- pool.Warm(context.Background())
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if count := atomic.LoadInt32(opened); count != 1 {
+		t.Errorf("Expected warming to wait for the only slot of the endpoint, but %d connections were opened", count)
+	}
+
+	close(release)
+	if err := <-requested; err != nil {
+		t.Fatalf("Unexpected error making request: %v", err)
+	}
+	if err := <-warmed; err != nil {
+		t.Fatalf("Unexpected error warming pool: %v", err)
+	}
+}
+
+func TestWarm_IdleConnectionsAreClosedAfterTheIdleConnTimeout(t *testing.T) {
+	server, _, closed := newConnectionCountingServer()
+	defer server.Close()
+
+	pool := sling.NewConnectionPool(sling.Config{PoolSize: 2, IdleConnTimeout: 10 * time.Millisecond})
+	pool.HTTP(server.URL)
+	if err := pool.Warm(context.Background()); err != nil {
+		t.Fatalf("Unexpected error warming pool: %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(closed) != 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if count := atomic.LoadInt32(closed); count != 2 {
+		t.Errorf("Expected %d idle connections to have been closed, but %d were", 2, count)
+	}
+}
+
+func TestWarm_FailsOnceThePoolIsClosed(t *testing.T) {
+	pool := sling.NewConnectionPool(sling.Config{})
+	pool.Shutdown(context.Background())
+	if err := pool.Warm(context.Background()); err != sling.ErrPoolClosed {
+		t.Errorf("Expected %v, but got %v", sling.ErrPoolClosed, err)
+	}
+}